@@ -0,0 +1,239 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// activitySamplerQuery counts the backends doing something, by state and
+	// wait event. Idle client backends and background processes waiting for
+	// work in their main loop are skipped, as is the sampler itself.
+	activitySamplerQuery = `
+SELECT COALESCE(state, '')
+     , COALESCE(wait_event_type, '')
+     , COALESCE(wait_event, '')
+     , count(*)
+  FROM pg_stat_activity
+ WHERE pid <> pg_backend_pid()
+   AND state IS DISTINCT FROM 'idle'
+   AND wait_event_type IS DISTINCT FROM 'Activity'
+ GROUP BY 1, 2, 3 /*postgres_exporter*/`
+
+	// DefaultActivitySamplerInterval is the default time between two samples
+	DefaultActivitySamplerInterval = 500 * time.Millisecond
+)
+
+// activityKey identifies the backends counted together by the sampler.
+type activityKey struct {
+	state         string
+	waitEventType string
+	waitEvent     string
+}
+
+// ActivitySampler polls pg_stat_activity on a dedicated connection much more
+// often than Prometheus scrapes, and accumulates the time spent by backends
+// in each state and wait event. Each backend seen in a sample is accounted
+// for the time elapsed since the previous sample, like an active session
+// history.
+type ActivitySampler struct {
+	logger     *slog.Logger
+	connConfig func() *pgx.ConnConfig
+	interval   time.Duration
+
+	mu             sync.Mutex
+	backendSeconds map[activityKey]float64
+	samples        float64
+	errors         float64
+
+	backendSecondsDesc *prometheus.Desc
+	samplesDesc        *prometheus.Desc
+	errorsDesc         *prometheus.Desc
+}
+
+// Verify ActivitySampler satisfies the prometheus.Collector interface
+var _ prometheus.Collector = (*ActivitySampler)(nil)
+
+// NewActivitySampler returns a sampler polling the server of the config
+// returned by connConfig every interval. connConfig is called before each
+// sample, so the sampler follows configuration reloads. It does nothing until
+// Run is called.
+func NewActivitySampler(logger *slog.Logger, connConfig func() *pgx.ConnConfig, interval time.Duration) *ActivitySampler {
+	return &ActivitySampler{
+		logger:         logger,
+		connConfig:     connConfig,
+		interval:       interval,
+		backendSeconds: make(map[activityKey]float64),
+		backendSecondsDesc: prometheus.NewDesc(
+			"postgres_activity_sampler_backend_seconds_total",
+			"Time spent by backends by state and wait event, estimated from pg_stat_activity samples. wait_event_type and wait_event are empty when not waiting, i.e. running on CPU",
+			[]string{"state", "wait_event_type", "wait_event"},
+			nil,
+		),
+		samplesDesc: prometheus.NewDesc(
+			"postgres_activity_sampler_samples_total",
+			"Number of pg_stat_activity samples taken",
+			nil,
+			nil,
+		),
+		errorsDesc: prometheus.NewDesc(
+			"postgres_activity_sampler_errors_total",
+			"Number of pg_stat_activity samples that failed",
+			nil,
+			nil,
+		),
+	}
+}
+
+// Run samples pg_stat_activity every interval until ctx is canceled. The
+// connection is opened on the first sample, and opened again after a failure
+// or when the data source changes.
+func (s *ActivitySampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var conn *pgx.Conn
+	defer func() {
+		if conn != nil {
+			// ctx is canceled already
+			_ = conn.Close(context.Background())
+		}
+	}()
+
+	// time of the previous successful sample, zero after a failure
+	var last time.Time
+	healthy := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		conn, err = s.connect(ctx, conn)
+		now := time.Now()
+		if err == nil {
+			// ticks are dropped when a sample or a reconnection is slow,
+			// account for the time actually elapsed
+			elapsed := s.interval
+			if !last.IsZero() {
+				elapsed = now.Sub(last)
+			}
+			err = s.sample(ctx, conn, elapsed)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if conn != nil {
+				_ = conn.Close(ctx)
+				conn = nil
+			}
+			last = time.Time{}
+
+			s.mu.Lock()
+			s.errors++
+			s.mu.Unlock()
+
+			// log once until the sampler recovers, not every interval
+			if healthy {
+				s.logger.Error("activity sampler",
+					slog.Any(errorKey, err))
+			}
+			healthy = false
+			continue
+		}
+		last = now
+
+		if !healthy {
+			s.logger.Info("activity sampler recovered")
+		}
+		healthy = true
+	}
+}
+
+// connect returns conn, or a new connection when conn is nil or was opened
+// with a data source that has been reloaded since.
+func (s *ActivitySampler) connect(ctx context.Context, conn *pgx.Conn) (*pgx.Conn, error) {
+	connConfig := s.connConfig()
+	if conn != nil {
+		if conn.Config().ConnString() == connConfig.ConnString() {
+			return conn, nil
+		}
+		s.logger.Info("activity sampler reconnecting, data source changed")
+		_ = conn.Close(ctx)
+	}
+
+	return pgx.ConnectConfig(ctx, connConfig)
+}
+
+// sample takes one sample of pg_stat_activity, and accounts each backend
+// for elapsed.
+func (s *ActivitySampler) sample(ctx context.Context, conn *pgx.Conn, elapsed time.Duration) error {
+	// a sample must not outlast the next one
+	ctx, cancel := context.WithTimeout(ctx, s.interval)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, activitySamplerQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	counts := make(map[activityKey]int64)
+	var key activityKey
+	var count int64
+
+	for rows.Next() {
+		if err := rows.Scan(&key.state,
+			&key.waitEventType,
+			&key.waitEvent,
+			&count,
+		); err != nil {
+			return err
+		}
+		counts[key] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// only account for complete samples
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, count := range counts {
+		s.backendSeconds[key] += float64(count) * elapsed.Seconds()
+	}
+	s.samples++
+
+	return nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (s *ActivitySampler) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.backendSecondsDesc
+	ch <- s.samplesDesc
+	ch <- s.errorsDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (s *ActivitySampler) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, seconds := range s.backendSeconds {
+		ch <- prometheus.MustNewConstMetric(s.backendSecondsDesc, prometheus.CounterValue, seconds,
+			key.state, key.waitEventType, key.waitEvent)
+	}
+	ch <- prometheus.MustNewConstMetric(s.samplesDesc, prometheus.CounterValue, s.samples)
+	ch <- prometheus.MustNewConstMetric(s.errorsDesc, prometheus.CounterValue, s.errors)
+}
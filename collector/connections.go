@@ -0,0 +1,260 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// connectionsQuery returns the connection and worker limits of the
+	// server, with the backends using them. reserved_connections was added
+	// in PostgreSQL 16. Background workers, including parallel workers and
+	// logical replication workers, show their own backend_type, so they are
+	// counted as every backend that is not a core process. The placeholder
+	// is filled with the backend types using a max_connections slot.
+	connectionsQuery = `
+SELECT current_setting('max_connections')::float8
+     , current_setting('superuser_reserved_connections')::float8
+       + COALESCE(current_setting('reserved_connections', true), '0')::float8
+     , count(*) FILTER (WHERE backend_type IN (%s))::float8
+     , current_setting('autovacuum_max_workers')::float8
+     , count(*) FILTER (WHERE backend_type = 'autovacuum worker')::float8
+     , current_setting('max_wal_senders')::float8
+     , count(*) FILTER (WHERE backend_type = 'walsender')::float8
+     , current_setting('max_worker_processes')::float8
+     , count(*) FILTER (WHERE backend_type NOT IN ('client backend'
+                                                 , 'autovacuum launcher'
+                                                 , 'autovacuum worker'
+                                                 , 'walsender'
+                                                 , 'walreceiver'
+                                                 , 'walwriter'
+                                                 , 'wal summarizer'
+                                                 , 'background writer'
+                                                 , 'checkpointer'
+                                                 , 'startup'
+                                                 , 'archiver'
+                                                 , 'slotsync worker'
+                                                 , 'io worker'
+                                                 , 'standalone backend'))::float8
+  FROM pg_stat_activity /*postgres_exporter*/`
+
+	// Client connections of the databases with a connection limit
+	connectionsDatabaseQuery = `
+SELECT d.datname
+     , d.datconnlimit::float8
+     , count(a.pid)::float8
+  FROM pg_database d
+  LEFT JOIN pg_stat_activity a ON a.datid = d.oid
+                              AND a.backend_type = 'client backend'
+ WHERE d.datconnlimit >= 0
+ GROUP BY d.datname, d.datconnlimit /*postgres_exporter*/`
+
+	// Client connections of the roles with a connection limit
+	connectionsRoleQuery = `
+SELECT r.rolname
+     , r.rolconnlimit::float8
+     , count(a.pid)::float8
+  FROM pg_roles r
+  LEFT JOIN pg_stat_activity a ON a.usesysid = r.oid
+                              AND a.backend_type = 'client backend'
+ WHERE r.rolconnlimit >= 0
+   AND r.rolcanlogin
+ GROUP BY r.rolname, r.rolconnlimit /*postgres_exporter*/`
+
+	// walsenders used a max_connections slot before PostgreSQL 12
+	connectionsUsed11 = `'client backend', 'walsender'`
+	connectionsUsed   = `'client backend'`
+
+	// pg_stat_activity.backend_type was added in PostgreSQL 10
+	connectionsVersion              = 100000
+	connectionsWalSenderSlotVersion = 120000
+)
+
+type connectionsScraper struct {
+	max               *prometheus.Desc
+	reserved          *prometheus.Desc
+	used              *prometheus.Desc
+	available         *prometheus.Desc
+	databaseLimit     *prometheus.Desc
+	databaseUsed      *prometheus.Desc
+	databaseAvailable *prometheus.Desc
+	roleLimit         *prometheus.Desc
+	roleUsed          *prometheus.Desc
+	roleAvailable     *prometheus.Desc
+	workerSlotsMax    *prometheus.Desc
+	workerSlotsUsed   *prometheus.Desc
+}
+
+// NewConnectionsScraper returns a new Scraper exposing the connection slots
+// used and available against the server, database and role limits, and the
+// worker slots used by autovacuum, walsenders and background workers
+func NewConnectionsScraper() Scraper {
+	return &connectionsScraper{
+		max: prometheus.NewDesc(
+			"postgres_connections_max",
+			"Maximum number of concurrent client connections, max_connections",
+			nil,
+			nil,
+		),
+		reserved: prometheus.NewDesc(
+			"postgres_connections_reserved",
+			"Connection slots reserved for superusers and roles with pg_use_reserved_connections, superuser_reserved_connections + reserved_connections",
+			nil,
+			nil,
+		),
+		used: prometheus.NewDesc(
+			"postgres_connections_used",
+			"Number of connection slots used by client connections, and by walsenders before PostgreSQL 12",
+			nil,
+			nil,
+		),
+		available: prometheus.NewDesc(
+			"postgres_connections_available",
+			"Connection slots left for roles without reserved connections, max_connections - reserved - used",
+			nil,
+			nil,
+		),
+		databaseLimit: prometheus.NewDesc(
+			"postgres_database_connections_limit",
+			"Maximum number of concurrent connections to the database, datconnlimit. Only for databases with a limit",
+			[]string{"datname"},
+			nil,
+		),
+		databaseUsed: prometheus.NewDesc(
+			"postgres_database_connections_used",
+			"Number of client connections to the database. Only for databases with a limit",
+			[]string{"datname"},
+			nil,
+		),
+		databaseAvailable: prometheus.NewDesc(
+			"postgres_database_connections_available",
+			"Connection slots left before reaching the limit of the database. Only for databases with a limit",
+			[]string{"datname"},
+			nil,
+		),
+		roleLimit: prometheus.NewDesc(
+			"postgres_role_connections_limit",
+			"Maximum number of concurrent connections of the role, rolconnlimit. Only for roles with a limit",
+			[]string{"rolname"},
+			nil,
+		),
+		roleUsed: prometheus.NewDesc(
+			"postgres_role_connections_used",
+			"Number of client connections of the role. Only for roles with a limit",
+			[]string{"rolname"},
+			nil,
+		),
+		roleAvailable: prometheus.NewDesc(
+			"postgres_role_connections_available",
+			"Connection slots left before reaching the limit of the role. Only for roles with a limit",
+			[]string{"rolname"},
+			nil,
+		),
+		workerSlotsMax: prometheus.NewDesc(
+			"postgres_worker_slots_max",
+			"Maximum number of processes of the kind, from autovacuum_max_workers, max_wal_senders and max_worker_processes",
+			[]string{"kind"},
+			nil,
+		),
+		workerSlotsUsed: prometheus.NewDesc(
+			"postgres_worker_slots_used",
+			"Number of running processes of the kind, autovacuum workers, walsenders or background workers",
+			[]string{"kind"},
+			nil,
+		),
+	}
+}
+
+func (*connectionsScraper) Name() string {
+	return "ConnectionsScraper"
+}
+
+func (c *connectionsScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.max
+	ch <- c.reserved
+	ch <- c.used
+	ch <- c.available
+	ch <- c.databaseLimit
+	ch <- c.databaseUsed
+	ch <- c.databaseAvailable
+	ch <- c.roleLimit
+	ch <- c.roleUsed
+	ch <- c.roleAvailable
+	ch <- c.workerSlotsMax
+	ch <- c.workerSlotsUsed
+}
+
+func (c *connectionsScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(connectionsVersion) {
+		return nil
+	}
+
+	usedTypes := connectionsUsed11
+	if version.Gte(connectionsWalSenderSlotVersion) {
+		usedTypes = connectionsUsed
+	}
+
+	var maxConnections, reserved, used, autovacuumMax, autovacuumUsed,
+		walSendersMax, walSendersUsed, workersMax, workersUsed float64
+
+	if err := conn.QueryRow(ctx, fmt.Sprintf(connectionsQuery, usedTypes)).
+		Scan(&maxConnections,
+			&reserved,
+			&used,
+			&autovacuumMax,
+			&autovacuumUsed,
+			&walSendersMax,
+			&walSendersUsed,
+			&workersMax,
+			&workersUsed,
+		); err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, maxConnections)
+	ch <- prometheus.MustNewConstMetric(c.reserved, prometheus.GaugeValue, reserved)
+	ch <- prometheus.MustNewConstMetric(c.used, prometheus.GaugeValue, used)
+	// superusers can use the reserved slots, never report less than none left
+	ch <- prometheus.MustNewConstMetric(c.available, prometheus.GaugeValue, max(maxConnections-reserved-used, 0))
+
+	ch <- prometheus.MustNewConstMetric(c.workerSlotsMax, prometheus.GaugeValue, autovacuumMax, "autovacuum")
+	ch <- prometheus.MustNewConstMetric(c.workerSlotsUsed, prometheus.GaugeValue, autovacuumUsed, "autovacuum")
+	ch <- prometheus.MustNewConstMetric(c.workerSlotsMax, prometheus.GaugeValue, walSendersMax, "walsender")
+	ch <- prometheus.MustNewConstMetric(c.workerSlotsUsed, prometheus.GaugeValue, walSendersUsed, "walsender")
+	ch <- prometheus.MustNewConstMetric(c.workerSlotsMax, prometheus.GaugeValue, workersMax, "background_worker")
+	ch <- prometheus.MustNewConstMetric(c.workerSlotsUsed, prometheus.GaugeValue, workersUsed, "background_worker")
+
+	if err := c.scrapeLimits(ctx, conn, ch, connectionsDatabaseQuery, c.databaseLimit, c.databaseUsed, c.databaseAvailable); err != nil {
+		return err
+	}
+
+	return c.scrapeLimits(ctx, conn, ch, connectionsRoleQuery, c.roleLimit, c.roleUsed, c.roleAvailable)
+}
+
+// scrapeLimits scrapes the connections of the databases or roles returned by
+// query against their limit.
+func (*connectionsScraper) scrapeLimits(ctx context.Context, conn *pgx.Conn, ch chan<- prometheus.Metric, query string, limitDesc, usedDesc, availableDesc *prometheus.Desc) error {
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var name string
+	var limit, used float64
+
+	for rows.Next() {
+		if err := rows.Scan(&name, &limit, &used); err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(limitDesc, prometheus.GaugeValue, limit, name)
+		ch <- prometheus.MustNewConstMetric(usedDesc, prometheus.GaugeValue, used, name)
+		ch <- prometheus.MustNewConstMetric(availableDesc, prometheus.GaugeValue, max(limit-used, 0), name)
+	}
+
+	return rows.Err()
+}
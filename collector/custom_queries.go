@@ -0,0 +1,344 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	customScopeServer   = "server"
+	customScopeDatabase = "database"
+
+	customTypeGauge   = "gauge"
+	customTypeCounter = "counter"
+)
+
+// reservedMetricPrefixes are the prefixes of the metrics served on /metrics
+// next to the scrapers, by the Go, process and promhttp collectors, the
+// exporter itself and the activity sampler. A custom metric using one of
+// them could collide with a metric that is not known when the queries are
+// validated.
+var reservedMetricPrefixes = []string{
+	"go_",
+	"process_",
+	"promhttp_",
+	"postgres_exporter_",
+	"postgres_activity_sampler_",
+}
+
+// CustomQuery is a user-defined query turned into a Scraper. Each row of the
+// query produces one sample per metric, labelled with the label columns.
+type CustomQuery struct {
+	// Name is the prefix of the metrics, which are named <name>_<column>.
+	Name string `yaml:"name"`
+	// Query is the SQL query to run.
+	Query string `yaml:"query"`
+	// Scope is either "server" (default), to run the query once on the server
+	// connection, or "database" to run it on every database.
+	Scope string `yaml:"scope"`
+	// Databases limits a database scoped query to the given databases.
+	Databases []string `yaml:"databases"`
+	// MinVersion skips the query on servers older than this version, e.g.
+	// "16" or "9.6". YAML numbers, e.g. 16 or 9.6, are decoded as their
+	// text, so both forms are accepted.
+	MinVersion string `yaml:"min_version"`
+	// Timeout overrides the default scraper timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// Labels are the columns used as labels.
+	Labels []string `yaml:"labels"`
+	// Metrics are the columns used as values.
+	Metrics []CustomMetric `yaml:"metrics"`
+
+	// minVersion is MinVersion in the server_version_num format
+	minVersion int
+}
+
+// CustomMetric is a value column of a CustomQuery.
+type CustomMetric struct {
+	Column string `yaml:"column"`
+	// Type is either "gauge" (default) or "counter".
+	Type string `yaml:"type"`
+	Help string `yaml:"help"`
+}
+
+type customQueriesFile struct {
+	Queries []CustomQuery `yaml:"queries"`
+}
+
+// LoadCustomQueries reads and validates the custom queries file. An empty path
+// returns no queries.
+func LoadCustomQueries(path string) ([]CustomQuery, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading custom queries file: %w", err)
+	}
+
+	var file customQueriesFile
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing custom queries file %q: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(file.Queries))
+	for i := range file.Queries {
+		q := &file.Queries[i]
+		if err := q.validate(); err != nil {
+			return nil, fmt.Errorf("custom query %q: %w", q.Name, err)
+		}
+		if names[q.Name] {
+			return nil, fmt.Errorf("custom query %q: duplicated name", q.Name)
+		}
+		names[q.Name] = true
+	}
+
+	return file.Queries, nil
+}
+
+// validate checks the query and fills in the defaults.
+func (q *CustomQuery) validate() error {
+	if q.Name == "" || !model.IsValidLegacyMetricName(q.Name) {
+		return errors.New("invalid name")
+	}
+	if q.Query == "" {
+		return errors.New("empty query")
+	}
+
+	if q.MinVersion != "" {
+		version, err := ParseVersion(q.MinVersion)
+		if err != nil {
+			return fmt.Errorf("invalid min_version: %w", err)
+		}
+		q.minVersion = version.Num()
+	}
+
+	switch q.Scope {
+	case "":
+		q.Scope = customScopeServer
+	case customScopeServer, customScopeDatabase:
+	default:
+		return fmt.Errorf("invalid scope %q", q.Scope)
+	}
+	if len(q.Databases) > 0 && q.Scope != customScopeDatabase {
+		return errors.New("databases requires the database scope")
+	}
+
+	for _, label := range q.Labels {
+		if !model.LabelName(label).IsValidLegacy() {
+			return fmt.Errorf("invalid label %q", label)
+		}
+		if label == "datname" && q.Scope == customScopeDatabase {
+			return errors.New("label datname is added to database scoped queries")
+		}
+	}
+
+	if len(q.Metrics) == 0 {
+		return errors.New("no metrics")
+	}
+	for i := range q.Metrics {
+		m := &q.Metrics[i]
+		name := q.Name + "_" + m.Column
+		if !model.IsValidLegacyMetricName(name) {
+			return fmt.Errorf("invalid column %q", m.Column)
+		}
+		for _, prefix := range reservedMetricPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				return fmt.Errorf("column %q: metric name %q uses the reserved prefix %q", m.Column, name, prefix)
+			}
+		}
+		if slices.Contains(q.Labels, m.Column) {
+			return fmt.Errorf("column %q used both as label and metric", m.Column)
+		}
+		switch m.Type {
+		case "":
+			m.Type = customTypeGauge
+		case customTypeGauge, customTypeCounter:
+		default:
+			return fmt.Errorf("column %q: invalid type %q", m.Column, m.Type)
+		}
+	}
+
+	return nil
+}
+
+type customQueryMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+}
+
+type customQueryScraper struct {
+	query   CustomQuery
+	metrics map[string]customQueryMetric
+}
+
+// NewCustomQueryScraper returns a new Scraper exposing the result of a user-defined query
+func NewCustomQueryScraper(q CustomQuery) Scraper {
+	labels := slices.Clone(q.Labels)
+	if q.Scope == customScopeDatabase {
+		labels = append(labels, "datname")
+	}
+
+	metrics := make(map[string]customQueryMetric, len(q.Metrics))
+	for _, m := range q.Metrics {
+		help := m.Help
+		if help == "" {
+			help = fmt.Sprintf("Column %s of custom query %s", m.Column, q.Name)
+		}
+
+		valueType := prometheus.GaugeValue
+		if m.Type == customTypeCounter {
+			valueType = prometheus.CounterValue
+		}
+
+		metrics[m.Column] = customQueryMetric{
+			desc:      prometheus.NewDesc(q.Name+"_"+m.Column, help, labels, nil),
+			valueType: valueType,
+		}
+	}
+
+	return &customQueryScraper{
+		query:   q,
+		metrics: metrics,
+	}
+}
+
+func (c *customQueryScraper) Name() string {
+	return "CustomQueryScraper/" + c.query.Name
+}
+
+func (c *customQueryScraper) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics {
+		ch <- m.desc
+	}
+}
+
+// runsOn implements databaseFilter.
+func (c *customQueryScraper) runsOn(datname string) bool {
+	return len(c.query.Databases) == 0 || slices.Contains(c.query.Databases, datname)
+}
+
+func (c *customQueryScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(c.query.minVersion) {
+		return nil
+	}
+
+	rows, err := conn.Query(ctx, c.query.Query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns := make([]string, 0, len(rows.FieldDescriptions()))
+	for _, fd := range rows.FieldDescriptions() {
+		columns = append(columns, fd.Name)
+	}
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+
+		if err := c.emit(row, conn.Config().Database, ch); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// emit sends the metrics of a single row.
+func (c *customQueryScraper) emit(row map[string]any, datname string, ch chan<- prometheus.Metric) error {
+	labelValues := make([]string, 0, len(c.query.Labels)+1)
+	for _, label := range c.query.Labels {
+		value, ok := row[label]
+		if !ok {
+			return fmt.Errorf("missing label column %q", label)
+		}
+		if value == nil {
+			labelValues = append(labelValues, "")
+			continue
+		}
+		labelValues = append(labelValues, fmt.Sprint(value))
+	}
+	if c.query.Scope == customScopeDatabase {
+		labelValues = append(labelValues, datname)
+	}
+
+	for column, metric := range c.metrics {
+		value, ok := row[column]
+		if !ok {
+			return fmt.Errorf("missing metric column %q", column)
+		}
+
+		f, ok := toFloat64(value)
+		if !ok {
+			// NULL or not a number, nothing to expose
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(metric.desc, metric.valueType, f, labelValues...)
+	}
+
+	return nil
+}
+
+// toFloat64 converts a value returned by pgx to a sample value.
+func toFloat64(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		return float64(v.UTC().Unix()), true
+	case pgtype.Interval:
+		if !v.Valid {
+			return 0, false
+		}
+		// months are counted as 30 days, like justify_interval()
+		days := float64(v.Months)*30 + float64(v.Days)
+		return days*24*60*60 + float64(v.Microseconds)/1e6, true
+	case pgtype.Numeric:
+		f, err := v.Float64Value()
+		if err != nil || !f.Valid {
+			return 0, false
+		}
+		return f.Float64, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCustomQueriesMinVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{in: `"12"`, want: 120000},
+		{in: `"9.6"`, want: 90600},
+		// numbers, as accepted when min_version was a float
+		{in: `10`, want: 100000},
+		{in: `9.6`, want: 90600},
+		{in: `13.0`, want: 130000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "queries.yml")
+			content := `
+queries:
+  - name: app
+    min_version: ` + tt.in + `
+    query: SELECT 1 AS value
+    metrics:
+      - column: value
+`
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			queries, err := LoadCustomQueries(path)
+			if err != nil {
+				t.Fatalf("LoadCustomQueries returned error: %v", err)
+			}
+			if got := queries[0].minVersion; got != tt.want {
+				t.Errorf("minVersion = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
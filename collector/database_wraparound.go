@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Scrape query, template databases included since they also need to be
+	// frozen
+	databaseWraparoundQuery = `
+SELECT datname
+     , age(datfrozenxid)::float8
+     , mxid_age(datminmxid)::float8
+     , current_setting('autovacuum_freeze_max_age')::float8
+     , current_setting('autovacuum_multixact_freeze_max_age')::float8
+  FROM pg_database /*postgres_exporter*/`
+
+	// mxid_age() was added in PostgreSQL 9.5
+	mxidAgeVersion = 90500
+
+	// wraparoundLimit is the maximum age of a transaction ID or MultiXact ID,
+	// the server refuses to assign new ones shortly before reaching it
+	wraparoundLimit = 1 << 31
+)
+
+type databaseWraparoundScraper struct {
+	xidAge                  *prometheus.Desc
+	mxidAge                 *prometheus.Desc
+	xidFreezeRemaining      *prometheus.Desc
+	mxidFreezeRemaining     *prometheus.Desc
+	xidWraparoundRemaining  *prometheus.Desc
+	mxidWraparoundRemaining *prometheus.Desc
+}
+
+// NewDatabaseWraparoundScraper returns a new Scraper exposing the transaction
+// ID and MultiXact ID age of each database, and how far they are from
+// anti-wraparound autovacuums and from the wraparound limit
+func NewDatabaseWraparoundScraper() Scraper {
+	return &databaseWraparoundScraper{
+		xidAge: prometheus.NewDesc(
+			"postgres_database_xid_age",
+			"Age of the oldest unfrozen transaction ID of the database, age(datfrozenxid)",
+			[]string{"datname"},
+			nil,
+		),
+		mxidAge: prometheus.NewDesc(
+			"postgres_database_mxid_age",
+			"Age of the oldest unfrozen MultiXact ID of the database, mxid_age(datminmxid)",
+			[]string{"datname"},
+			nil,
+		),
+		xidFreezeRemaining: prometheus.NewDesc(
+			"postgres_database_xid_freeze_max_age_remaining",
+			"Transaction IDs left before autovacuum_freeze_max_age forces an anti-wraparound autovacuum, negative once reached",
+			[]string{"datname"},
+			nil,
+		),
+		mxidFreezeRemaining: prometheus.NewDesc(
+			"postgres_database_mxid_freeze_max_age_remaining",
+			"MultiXact IDs left before autovacuum_multixact_freeze_max_age forces an anti-wraparound autovacuum, negative once reached",
+			[]string{"datname"},
+			nil,
+		),
+		xidWraparoundRemaining: prometheus.NewDesc(
+			"postgres_database_xid_wraparound_remaining",
+			"Transaction IDs left before the 2^31 wraparound limit, the server stops assigning new ones shortly before",
+			[]string{"datname"},
+			nil,
+		),
+		mxidWraparoundRemaining: prometheus.NewDesc(
+			"postgres_database_mxid_wraparound_remaining",
+			"MultiXact IDs left before the 2^31 wraparound limit, the server stops assigning new ones shortly before",
+			[]string{"datname"},
+			nil,
+		),
+	}
+}
+
+func (*databaseWraparoundScraper) Name() string {
+	return "DatabaseWraparoundScraper"
+}
+
+func (c *databaseWraparoundScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.xidAge
+	ch <- c.mxidAge
+	ch <- c.xidFreezeRemaining
+	ch <- c.mxidFreezeRemaining
+	ch <- c.xidWraparoundRemaining
+	ch <- c.mxidWraparoundRemaining
+}
+
+func (c *databaseWraparoundScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(mxidAgeVersion) {
+		return nil
+	}
+
+	rows, err := conn.Query(ctx, databaseWraparoundQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var datname string
+	var xidAge, mxidAge, freezeMaxAge, multixactFreezeMaxAge float64
+
+	for rows.Next() {
+		if err := rows.Scan(&datname,
+			&xidAge,
+			&mxidAge,
+			&freezeMaxAge,
+			&multixactFreezeMaxAge,
+		); err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.xidAge, prometheus.GaugeValue, xidAge, datname)
+		ch <- prometheus.MustNewConstMetric(c.mxidAge, prometheus.GaugeValue, mxidAge, datname)
+		ch <- prometheus.MustNewConstMetric(c.xidFreezeRemaining, prometheus.GaugeValue, freezeMaxAge-xidAge, datname)
+		ch <- prometheus.MustNewConstMetric(c.mxidFreezeRemaining, prometheus.GaugeValue, multixactFreezeMaxAge-mxidAge, datname)
+		ch <- prometheus.MustNewConstMetric(c.xidWraparoundRemaining, prometheus.GaugeValue, wraparoundLimit-xidAge, datname)
+		ch <- prometheus.MustNewConstMetric(c.mxidWraparoundRemaining, prometheus.GaugeValue, wraparoundLimit-mxidAge, datname)
+	}
+
+	return rows.Err()
+}
@@ -49,6 +49,11 @@ func (*diskUsageScraper) Name() string {
 	return "DiskUsageScraper"
 }
 
+func (c *diskUsageScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.indexUsage
+	ch <- c.tableUsage
+}
+
 func (c *diskUsageScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
 	var datname, schemaname, tablename, indexname string
 	var sizeBytes float64
@@ -2,10 +2,12 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -13,14 +15,14 @@ import (
 )
 
 const (
-	versionBitSize   = 64
-	infoQuery        = `SHOW server_version /*postgres_exporter*/`
 	listDatnameQuery = `
 SELECT datname FROM pg_database
 WHERE datallowconn = true AND datistemplate = false
 AND datname != ALL($1) /*postgres_exporter*/`
 	successValue    = 1.0
 	failureValue    = 0.0
+	timedOutValue   = 1.0
+	inTimeValue     = 0.0
 	infoMetricValue = 1.0
 	errorKey        = "error"
 )
@@ -32,10 +34,16 @@ var (
 		nil,
 		nil,
 	)
+	databaseUpDesc = prometheus.NewDesc(
+		"postgres_database_up",
+		"Whether the exporter could connect to the database to run the per-database scrapers.",
+		[]string{"datname"},
+		nil,
+	)
 	infoDesc = prometheus.NewDesc(
 		"postgres_info",
-		"Postgres version",
-		[]string{"version"},
+		"Postgres version, flavor is one of postgres, aurora, rds, alloydb, cloudsql or azure.",
+		[]string{"version", "server_version", "major", "minor", "flavor"},
 		nil,
 	)
 	scrapeDurationDesc = prometheus.NewDesc(
@@ -50,11 +58,22 @@ var (
 		[]string{"scraper", "datname"},
 		nil,
 	)
+	scrapeTimeoutDesc = prometheus.NewDesc(
+		"postgres_exporter_scraper_timeout",
+		"Whether a scraper failed because it ran out of time.",
+		[]string{"scraper", "datname"},
+		nil,
+	)
 )
 
+// errScrapeTimeout wraps the error of a scraper that ran out of time.
+var errScrapeTimeout = errors.New("scrape timeout")
+
 // Scraper is the interface each scraper has to implement.
 type Scraper interface {
 	Name() string
+	// Describe sends the descriptors of every metric the scraper can expose.
+	Describe(ch chan<- *prometheus.Desc)
 	// Scrape new metrics and expose them via prometheus registry.
 	Scrape(ctx context.Context, db *pgx.Conn, version Version, ch chan<- prometheus.Metric) error
 }
@@ -62,60 +81,36 @@ type Scraper interface {
 type Exporter struct {
 	ctx               context.Context
 	logger            *slog.Logger
+	pools             *Pools
 	connConfig        *pgx.ConnConfig
-	scrapers          []Scraper
-	datnameScrapers   []Scraper
+	scrapers          []enabledScraper
+	datnameScrapers   []enabledScraper
 	excludedDatabases []string
-}
-
-// Postgres Version
-type Version struct {
-	version float64
-}
-
-func NewVersion(v string) Version {
-	values := strings.Split(v, " ")
-	version, _ := strconv.ParseFloat(values[0], versionBitSize)
-	return Version{
-		version: version,
-	}
-}
-
-func (v Version) Gte(n float64) bool {
-	return v.version >= n
-}
-
-func (v Version) String() string {
-	return fmt.Sprintf("%g", v.version)
+	concurrency       int
 }
 
 // Verify our Exporter satisfies the prometheus.Collector interface
 var _ prometheus.Collector = (*Exporter)(nil)
 
 // NewExporter is called every time we receive a scrape request and knows how
-// to collect metrics using each of the scrapers. It will live only for the
-// duration of the scrape request.
-func NewExporter(ctx context.Context, logger *slog.Logger, connConfig *pgx.ConnConfig, excludedDatabases []string) *Exporter {
+// to collect metrics using each of the enabled scrapers. It will live only for
+// the duration of the scrape request, connections are borrowed from pools.
+func NewExporter(ctx context.Context, logger *slog.Logger, pools *Pools, connConfig *pgx.ConnConfig, cfg Config) *Exporter {
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	cfg.logger = logger
+
 	return &Exporter{
-		ctx:        ctx,
-		logger:     logger,
-		connConfig: connConfig,
-		scrapers: []Scraper{
-			NewInfoScraper(),
-			NewLocksScraper(),
-			NewStatActivityScraper(),
-			NewStatArchiverScraper(),
-			NewStatBgwriterScraper(),
-			NewStatDatabaseScraper(),
-			NewStatReplicationScraper(),
-		},
-		datnameScrapers: []Scraper{
-			NewStatVacuumProgressScraper(),
-			NewStatUserTablesScraper(),
-			NewStatUserIndexesScraper(),
-			NewDiskUsageScraper(),
-		},
-		excludedDatabases: excludedDatabases,
+		ctx:               ctx,
+		logger:            logger,
+		pools:             pools,
+		connConfig:        connConfig,
+		scrapers:          cfg.enabledScrapers(scopeServer),
+		datnameScrapers:   cfg.enabledScrapers(scopeDatabase),
+		excludedDatabases: cfg.ExcludedDatabases,
+		concurrency:       concurrency,
 	}
 }
 
@@ -123,11 +118,12 @@ func NewExporter(ctx context.Context, logger *slog.Logger, connConfig *pgx.ConnC
 func (Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- scrapeTimeoutDesc
 }
 
 // Collect implements the prometheus.Collector interface.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	conn, err := pgx.ConnectConfig(e.ctx, e.connConfig)
+	poolConn, err := e.pools.Acquire(e.ctx, e.connConfig)
 	if err != nil {
 		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, failureValue)
 		e.logger.Error("exporter collect",
@@ -135,20 +131,56 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		return // cannot continue without a valid connection
 	}
 
-	defer conn.Close(e.ctx)
 	// postgres_up
 	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, successValue)
 
-	var version string
-	if err := conn.QueryRow(e.ctx, infoQuery).Scan(&version); err != nil {
-		e.logger.Error("info query",
+	v, dbnames, err := e.discover(poolConn.Conn())
+	// give the connection back, so it can be used by the global scrapers
+	poolConn.Release()
+	if err != nil {
+		e.logger.Error("error discovery",
 			slog.Any(errorKey, err))
-		return // cannot continue without a version
+		return // cannot continue without a version and datnames
 	}
 
-	v := NewVersion(version)
 	// postgres_info
-	ch <- prometheus.MustNewConstMetric(infoDesc, prometheus.GaugeValue, infoMetricValue, v.String())
+	ch <- prometheus.MustNewConstMetric(infoDesc, prometheus.GaugeValue, infoMetricValue,
+		v.String(), v.Full(), v.Major(), strconv.Itoa(v.Minor()), v.Flavor())
+
+	// Global scrapers and databases run concurrently, at most
+	// e.concurrency at a time. Each of them holds a single connection.
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, e.concurrency)
+	run := func(f func()) {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			f()
+		})
+	}
+
+	// run global scrapers
+	for _, scraper := range e.scrapers {
+		run(func() { e.scrapeWithConfig(scraper, e.connConfig, v, ch) })
+	}
+
+	// run datname scrapers
+	if len(e.datnameScrapers) > 0 {
+		for _, dbname := range dbnames {
+			run(func() { e.scrapeDatabase(dbname, v, ch) })
+		}
+	}
+
+	wg.Wait()
+}
+
+// discover returns the server version and the databases to scrape.
+func (e *Exporter) discover(conn *pgx.Conn) (Version, []string, error) {
+	var num int
+	var full, flavor string
+	if err := conn.QueryRow(e.ctx, versionQuery).Scan(&num, &full, &flavor); err != nil {
+		return Version{}, nil, fmt.Errorf("error version query: %w", err)
+	}
 
 	// discovery databases
 	e.logger.Debug("excluded databases",
@@ -156,69 +188,130 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 	rows, err := conn.Query(e.ctx, listDatnameQuery, e.excludedDatabases)
 	if err != nil {
-		e.logger.Error("error query datnames",
-			slog.Any(errorKey, err))
+		return Version{}, nil, fmt.Errorf("error query datnames: %w", err)
 	}
 
-	var dbnames []string
-	dbnames, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	dbnames, err := pgx.CollectRows(rows, pgx.RowTo[string])
 	if err != nil {
-		e.logger.Error("error list datname query",
-			slog.Any(errorKey, err))
-		return
+		return Version{}, nil, fmt.Errorf("error list datname query: %w", err)
 	}
 
 	e.logger.Debug("debug datnames found",
 		slog.String("databases", strings.Join(dbnames, ",")))
 
-	// run global scrapers
-	for _, scraper := range e.scrapers {
-		e.scrape(scraper, conn, v, ch)
+	return NewVersion(num, full, flavor), dbnames, nil
+}
+
+// scrapeWithConfig borrows a connection for connConfig and runs the scraper.
+func (e *Exporter) scrapeWithConfig(scraper enabledScraper, connConfig *pgx.ConnConfig, version Version, ch chan<- prometheus.Metric) {
+	poolConn, err := e.pools.Acquire(e.ctx, connConfig)
+	if err != nil {
+		e.logger.Error("error pgx connection",
+			slog.Any(errorKey, err))
+		e.reportScrape(scraper, connConfig.Database, 0, err, ch)
+		return
 	}
+	defer poolConn.Release()
 
-	// run datname scrapers
-	for _, dbname := range dbnames {
-		// update connection dbname
-		e.connConfig.Database = dbname
-
-		// establish a new connection
-		conn, err := pgx.ConnectConfig(e.ctx, e.connConfig)
-		if err != nil {
-			e.logger.Error("error pgx connection",
-				slog.Any(errorKey, err))
-			return // cannot continue without a valid connection
+	e.scrape(scraper, poolConn.Conn(), connConfig.Database, version, ch)
+}
+
+// scrapeDatabase runs every datname scraper against a single database. A
+// database that cannot be reached is reported by postgres_database_up and
+// does not prevent the other databases from being scraped.
+func (e *Exporter) scrapeDatabase(dbname string, version Version, ch chan<- prometheus.Metric) {
+	connConfig := e.connConfig.Copy()
+	connConfig.Database = dbname
+
+	// borrow a connection from the database pool
+	poolConn, err := e.pools.Acquire(e.ctx, connConfig)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(databaseUpDesc, prometheus.GaugeValue, failureValue, dbname)
+		e.logger.Error("error pgx connection",
+			slog.String("datname", dbname),
+			slog.Any(errorKey, err))
+		return
+	}
+	defer func() {
+		if poolConn != nil {
+			poolConn.Release()
+		}
+	}()
+
+	// postgres_database_up
+	ch <- prometheus.MustNewConstMetric(databaseUpDesc, prometheus.GaugeValue, successValue, dbname)
+
+	for i, scraper := range e.datnameScrapers {
+		if !runsOn(scraper.Scraper, dbname) {
+			continue
 		}
 
-		// scrape
-		for _, scraper := range e.datnameScrapers {
-			e.scrape(scraper, conn, v, ch)
+		// A scraper that times out closes its connection, so borrow a new
+		// one from the database pool when needed
+		if poolConn.Conn().IsClosed() {
+			poolConn.Release()
+
+			poolConn, err = e.pools.Acquire(e.ctx, connConfig)
+			if err != nil {
+				e.logger.Error("error pgx connection",
+					slog.String("datname", dbname),
+					slog.Any(errorKey, err))
+				// cannot continue without a valid connection
+				for _, skipped := range e.datnameScrapers[i:] {
+					if runsOn(skipped.Scraper, dbname) {
+						e.reportScrape(skipped, dbname, 0, err, ch)
+					}
+				}
+				return
+			}
 		}
 
-		conn.Close(e.ctx)
+		e.scrape(scraper, poolConn.Conn(), dbname, version, ch)
 	}
 }
 
-func (e *Exporter) scrape(scraper Scraper, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) {
+// scrape runs the scraper within its time limit, which never goes past the
+// deadline of the scrape request.
+func (e *Exporter) scrape(scraper enabledScraper, conn *pgx.Conn, datname string, version Version, ch chan<- prometheus.Metric) {
+	ctx := e.ctx
+	if scraper.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, scraper.timeout)
+		defer cancel()
+	}
+
 	start := time.Now()
-	err := scraper.Scrape(e.ctx, conn, version, ch)
-	duration := time.Since(start)
+	err := scraper.Scrape(ctx, conn, version, ch)
+	// only the time limit of the scraper counts as a timeout, not the end of
+	// the scrape request
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && e.ctx.Err() == nil {
+		err = fmt.Errorf("%w: %w", errScrapeTimeout, err)
+	}
+	e.reportScrape(scraper, datname, time.Since(start), err, ch)
+}
 
-	var success float64
+// reportScrape logs the result of a scraper and exposes its duration, success
+// and whether it timed out.
+func (e *Exporter) reportScrape(scraper Scraper, datname string, duration time.Duration, err error, ch chan<- prometheus.Metric) {
+	success, timedOut := successValue, inTimeValue
 
 	logger := e.logger.With(
 		"scraper", scraper.Name(),
+		"datname", datname,
 		"duration", duration.Seconds())
 	if err != nil {
 		logger.Error("failed scrape",
 			slog.Any(errorKey, err))
 		success = failureValue
+		if errors.Is(err, errScrapeTimeout) {
+			timedOut = timedOutValue
+		}
 	} else {
 		logger.Debug("",
 			"event", "scraper.success")
-		success = successValue
 	}
 
-	datname := e.connConfig.Database
 	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), scraper.Name(), datname)
 	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, scraper.Name(), datname)
+	ch <- prometheus.MustNewConstMetric(scrapeTimeoutDesc, prometheus.GaugeValue, timedOut, scraper.Name(), datname)
 }
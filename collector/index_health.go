@@ -0,0 +1,321 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// indexHealthQuery returns the definition flags, size and scan count of
+	// each index, with its estimated bloat for btree indexes that have
+	// statistics. The bloat estimation is based on
+	// https://github.com/ioguix/pgsql-bloat-estimation. The placeholder is
+	// filled with the column holding the number of key columns. An index is
+	// redundant when its plain key columns, without INCLUDE columns, are the
+	// leading key columns of another index with the same operator classes,
+	// collations and predicate, which has more columns.
+	indexHealthQuery = `
+WITH btree_bloat AS (
+  SELECT idxoid
+       , CASE WHEN relpages > est_pages_ff THEN bs * (relpages - est_pages_ff) ELSE 0 END AS bloat_bytes
+       , CASE WHEN relpages > est_pages_ff THEN (relpages - est_pages_ff)::float8 / relpages ELSE 0 END AS bloat_ratio
+    FROM (
+      SELECT COALESCE(1 + ceil(reltuples / floor((bs - pageopqdata - pagehdr) * fillfactor / (100 * (4 + nulldatahdrwidth)::float8))), 0) AS est_pages_ff
+           , bs, idxoid, relpages, is_na
+        FROM (
+          SELECT bs, idxoid, reltuples, relpages, fillfactor, pagehdr, pageopqdata, is_na
+               , (index_tuple_hdr_bm
+                  + maxalign - CASE WHEN index_tuple_hdr_bm %% maxalign = 0 THEN maxalign ELSE index_tuple_hdr_bm %% maxalign END
+                  + nulldatawidth + maxalign - CASE WHEN nulldatawidth = 0 THEN 0
+                                                    WHEN nulldatawidth::int %% maxalign = 0 THEN maxalign
+                                                    ELSE nulldatawidth::int %% maxalign
+                                               END
+                 )::numeric AS nulldatahdrwidth
+            FROM (
+              SELECT i.idxoid
+                   , i.reltuples
+                   , i.relpages
+                   , i.fillfactor
+                   , current_setting('block_size')::numeric AS bs
+                   , 8 AS maxalign
+                   , 24 AS pagehdr
+                   , 16 AS pageopqdata
+                   , CASE WHEN max(COALESCE(s.null_frac, 0)) = 0 THEN 8 ELSE 8 + ((32 + 8 - 1) / 8) END AS index_tuple_hdr_bm
+                   , sum((1 - COALESCE(s.null_frac, 0)) * COALESCE(s.avg_width, 1024)) AS nulldatawidth
+                   , bool_or(i.atttypid = 'pg_catalog.name'::regtype) AS is_na
+                FROM (
+                  SELECT ic.idxoid, ic.idxname, ic.reltuples, ic.relpages, ic.fillfactor, ct.relnamespace
+                       , COALESCE(a1.attname, a2.attname) AS attname
+                       , COALESCE(a1.atttypid, a2.atttypid) AS atttypid
+                       , CASE WHEN a1.attnum IS NULL THEN ic.idxname ELSE ct.relname END AS attrelname
+                    FROM (
+                      SELECT ci.relname AS idxname
+                           , ci.reltuples
+                           , ci.relpages
+                           , x.indrelid AS tbloid
+                           , x.indexrelid AS idxoid
+                           , COALESCE(substring(array_to_string(ci.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 90) AS fillfactor
+                           , string_to_array(x.indkey::text, ' ')::int[] AS indkey
+                           , generate_series(1, x.indnatts) AS attpos
+                        FROM pg_index x
+                        JOIN pg_class ci ON ci.oid = x.indexrelid
+                       WHERE ci.relam = (SELECT oid FROM pg_am WHERE amname = 'btree')
+                         AND ci.relpages > 0
+                    ) AS ic
+                    JOIN pg_class ct ON ct.oid = ic.tbloid
+                    LEFT JOIN pg_attribute a1 ON ic.indkey[ic.attpos] <> 0
+                                             AND a1.attrelid = ic.tbloid
+                                             AND a1.attnum = ic.indkey[ic.attpos]
+                    LEFT JOIN pg_attribute a2 ON ic.indkey[ic.attpos] = 0
+                                             AND a2.attrelid = ic.idxoid
+                                             AND a2.attnum = ic.attpos
+                ) AS i
+                JOIN pg_namespace n ON n.oid = i.relnamespace
+                JOIN pg_stats s ON s.schemaname = n.nspname
+                               AND s.tablename = i.attrelname
+                               AND s.attname = i.attname
+               GROUP BY 1, 2, 3, 4
+            ) AS rows_data_stats
+        ) AS rows_hdr_pdg_stats
+    ) AS relation_stats
+   WHERE NOT is_na
+)
+SELECT n.nspname
+     , t.relname
+     , c.relname
+     , am.amname
+     , i.indisvalid
+     , i.indisready
+     , i.indisunique
+     , EXISTS (
+         SELECT 1
+           FROM pg_constraint con
+          WHERE con.conindid = i.indexrelid
+            AND con.conrelid = i.indrelid
+            AND con.contype IN ('p', 'u', 'x')
+       ) AS is_constraint
+     , EXISTS (
+         SELECT 1
+           FROM pg_index o
+          WHERE o.indrelid = i.indrelid
+            AND o.indexrelid < i.indexrelid
+            AND o.indisvalid
+            AND o.indkey::text = i.indkey::text
+            AND o.indclass::text = i.indclass::text
+            AND o.indcollation::text = i.indcollation::text
+            AND o.indisunique = i.indisunique
+            AND COALESCE(pg_get_expr(o.indexprs, o.indrelid), '') = COALESCE(pg_get_expr(i.indexprs, i.indrelid), '')
+            AND COALESCE(pg_get_expr(o.indpred, o.indrelid), '') = COALESCE(pg_get_expr(i.indpred, i.indrelid), '')
+            AND (SELECT relam FROM pg_class WHERE oid = o.indexrelid) = c.relam
+       ) AS is_duplicate
+     , am.amname = 'btree'
+       AND NOT i.indisunique
+       AND i.indexprs IS NULL
+       AND i.indnatts = i.%[1]s
+       AND EXISTS (
+         SELECT 1
+           FROM pg_index o
+          WHERE o.indrelid = i.indrelid
+            AND o.indexrelid <> i.indexrelid
+            AND o.indisvalid
+            AND o.%[1]s >= i.%[1]s
+            AND o.indnatts > i.indnatts
+            AND (string_to_array(o.indkey::text, ' '))[1:i.%[1]s] = string_to_array(i.indkey::text, ' ')
+            AND (string_to_array(o.indclass::text, ' '))[1:i.%[1]s] = string_to_array(i.indclass::text, ' ')
+            AND (string_to_array(o.indcollation::text, ' '))[1:i.%[1]s] = string_to_array(i.indcollation::text, ' ')
+            AND COALESCE(pg_get_expr(o.indpred, o.indrelid), '') = COALESCE(pg_get_expr(i.indpred, i.indrelid), '')
+            AND (SELECT relam FROM pg_class WHERE oid = o.indexrelid) = c.relam
+       ) AS is_redundant
+     , pg_relation_size(i.indexrelid)::float8
+     , COALESCE(s.idx_scan, 0)::float8
+     , b.bloat_bytes::float8
+     , b.bloat_ratio::float8
+  FROM pg_index i
+  JOIN pg_class c ON c.oid = i.indexrelid
+  JOIN pg_class t ON t.oid = i.indrelid
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+  JOIN pg_am am ON am.oid = c.relam
+  LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = i.indexrelid
+  LEFT JOIN btree_bloat b ON b.idxoid = i.indexrelid
+ WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+   AND n.nspname !~ '^pg_toast' /*postgres_exporter*/`
+
+	// indnkeyatts was added in PostgreSQL 11 with INCLUDE columns
+	indexHealthKeyAttsVersion = 110000
+)
+
+type indexHealthScraper struct {
+	info        *prometheus.Desc
+	valid       *prometheus.Desc
+	ready       *prometheus.Desc
+	unique      *prometheus.Desc
+	constraint  *prometheus.Desc
+	duplicate   *prometheus.Desc
+	redundant   *prometheus.Desc
+	unusedBytes *prometheus.Desc
+	bloatBytes  *prometheus.Desc
+	bloatRatio  *prometheus.Desc
+}
+
+// NewIndexHealthScraper returns a new Scraper exposing the state of each
+// index: whether it is valid, unique or backs a constraint, whether it
+// duplicates another index or is made redundant by it, the size of the
+// indexes never scanned, and the estimated bloat of btree indexes
+func NewIndexHealthScraper() Scraper {
+	labels := []string{"datname", "schemaname", "relname", "indexname"}
+	return &indexHealthScraper{
+		info: prometheus.NewDesc(
+			"postgres_index_info",
+			"Access method of the index",
+			append(labels, "access_method"),
+			nil,
+		),
+		valid: prometheus.NewDesc(
+			"postgres_index_valid",
+			"Whether the index is valid for queries, 0 after a failed CREATE INDEX CONCURRENTLY",
+			labels,
+			nil,
+		),
+		ready: prometheus.NewDesc(
+			"postgres_index_ready",
+			"Whether the index is ready for inserts, 0 while CREATE INDEX CONCURRENTLY is running",
+			labels,
+			nil,
+		),
+		unique: prometheus.NewDesc(
+			"postgres_index_unique",
+			"Whether the index is unique",
+			labels,
+			nil,
+		),
+		constraint: prometheus.NewDesc(
+			"postgres_index_constraint",
+			"Whether the index backs a primary key, unique or exclusion constraint",
+			labels,
+			nil,
+		),
+		duplicate: prometheus.NewDesc(
+			"postgres_index_duplicate",
+			"Whether the index has the same definition as an older index of the same table",
+			labels,
+			nil,
+		),
+		redundant: prometheus.NewDesc(
+			"postgres_index_redundant",
+			"Whether the columns of this non-unique btree index are the leading key columns of another valid index of the same table, with the same operator classes, collations and predicate",
+			labels,
+			nil,
+		),
+		unusedBytes: prometheus.NewDesc(
+			"postgres_index_unused_bytes",
+			"Size of the index if it has not been scanned since the statistics were reset and does not enforce uniqueness, 0 otherwise",
+			labels,
+			nil,
+		),
+		bloatBytes: prometheus.NewDesc(
+			"postgres_index_bloat_bytes",
+			"Estimated space wasted in this btree index beyond its fillfactor",
+			labels,
+			nil,
+		),
+		bloatRatio: prometheus.NewDesc(
+			"postgres_index_bloat_ratio",
+			"Estimated fraction of this btree index wasted beyond its fillfactor, between 0 and 1",
+			labels,
+			nil,
+		),
+	}
+}
+
+func (*indexHealthScraper) Name() string {
+	return "IndexHealthScraper"
+}
+
+func (c *indexHealthScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	ch <- c.valid
+	ch <- c.ready
+	ch <- c.unique
+	ch <- c.constraint
+	ch <- c.duplicate
+	ch <- c.redundant
+	ch <- c.unusedBytes
+	ch <- c.bloatBytes
+	ch <- c.bloatRatio
+}
+
+func (c *indexHealthScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	var datname string
+	if err := conn.QueryRow(ctx, "SELECT current_database() /*postgres_exporter*/").Scan(&datname); err != nil {
+		return err
+	}
+
+	keyAtts := "indnatts"
+	if version.Gte(indexHealthKeyAttsVersion) {
+		keyAtts = "indnkeyatts"
+	}
+
+	rows, err := conn.Query(ctx, fmt.Sprintf(indexHealthQuery, keyAtts))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var schemaname, relname, indexname, accessMethod string
+	var valid, ready, unique, constraint, duplicate, redundant bool
+	var sizeBytes, idxScan float64
+	// NULL for indexes that are not btree or have no statistics
+	var bloatBytes, bloatRatio *float64
+
+	for rows.Next() {
+		if err := rows.Scan(&schemaname,
+			&relname,
+			&indexname,
+			&accessMethod,
+			&valid,
+			&ready,
+			&unique,
+			&constraint,
+			&duplicate,
+			&redundant,
+			&sizeBytes,
+			&idxScan,
+			&bloatBytes,
+			&bloatRatio,
+		); err != nil {
+			return err
+		}
+
+		labels := []string{datname, schemaname, relname, indexname}
+
+		ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, infoMetricValue, append(labels, accessMethod)...)
+		ch <- prometheus.MustNewConstMetric(c.valid, prometheus.GaugeValue, boolValue(valid), labels...)
+		ch <- prometheus.MustNewConstMetric(c.ready, prometheus.GaugeValue, boolValue(ready), labels...)
+		ch <- prometheus.MustNewConstMetric(c.unique, prometheus.GaugeValue, boolValue(unique), labels...)
+		ch <- prometheus.MustNewConstMetric(c.constraint, prometheus.GaugeValue, boolValue(constraint), labels...)
+		ch <- prometheus.MustNewConstMetric(c.duplicate, prometheus.GaugeValue, boolValue(duplicate), labels...)
+		ch <- prometheus.MustNewConstMetric(c.redundant, prometheus.GaugeValue, boolValue(redundant), labels...)
+
+		unusedBytes := 0.0
+		if idxScan == 0 && !unique && !constraint {
+			unusedBytes = sizeBytes
+		}
+		ch <- prometheus.MustNewConstMetric(c.unusedBytes, prometheus.GaugeValue, unusedBytes, labels...)
+
+		emitGauge(ch, c.bloatBytes, bloatBytes, labels)
+		emitGauge(ch, c.bloatRatio, bloatRatio, labels)
+	}
+
+	return rows.Err()
+}
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -13,7 +13,7 @@ const (
 	isInBackupQuery             = `SELECT pg_is_in_backup()::int /*postgres_exporter*/`
 	startTimeQuery              = `SELECT pg_postmaster_start_time() /*postgres_exporter*/`
 	configLoadTimeQuery         = `SELECT pg_conf_load_time() /*postgres_exporter*/`
-	isInBackupDeprecatedVersion = 15.0
+	isInBackupDeprecatedVersion = 150000
 )
 
 type infoScraper struct {
@@ -57,8 +57,15 @@ func (*infoScraper) Name() string {
 	return "InfoScraper"
 }
 
+func (c *infoScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.isInRecovery
+	ch <- c.isInBackup
+	ch <- c.startTime
+	ch <- c.configLoadTime
+}
+
 func (c *infoScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
-	var recovery, backup int64
+	var recovery int64
 	var startTime, configLoadTime time.Time
 
 	if err := conn.QueryRow(ctx, isInRecoveryQuery).Scan(&recovery); err != nil {
@@ -67,10 +74,6 @@ func (c *infoScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Versio
 	// postgres_is_in_recovery
 	ch <- prometheus.MustNewConstMetric(c.isInRecovery, prometheus.GaugeValue, float64(recovery))
 
-	if err := conn.QueryRow(ctx, isInBackupQuery).Scan(&backup); err != nil {
-		return err
-	}
-
 	// postgres_is_in_backup was removed in PostgreSQL 15
 	if !version.Gte(isInBackupDeprecatedVersion) {
 		var backup int64
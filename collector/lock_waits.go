@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// lockWaitsCTE builds the blocking chains from every root blocker, a
+	// backend blocking others without waiting for a lock itself, down to the
+	// backends waiting on it. The path guards against deadlocks not detected
+	// yet. Prepared transactions are reported with pid 0.
+	lockWaitsCTE = `
+WITH RECURSIVE blocked AS (
+  SELECT pid
+       , pg_blocking_pids(pid) AS blockers
+    FROM pg_stat_activity
+   WHERE wait_event_type = 'Lock'
+), edges AS (
+  SELECT pid
+       , unnest(blockers) AS blocker
+    FROM blocked
+), chains AS (
+  SELECT blocker AS root
+       , pid
+       , 1 AS depth
+       , ARRAY[blocker, pid] AS path
+    FROM edges
+   WHERE blocker NOT IN (SELECT pid FROM blocked WHERE cardinality(blockers) > 0)
+   UNION ALL
+  SELECT c.root
+       , e.pid
+       , c.depth + 1
+       , c.path || e.pid
+    FROM chains c
+    JOIN edges e ON e.blocker = c.pid
+   WHERE NOT e.pid = ANY(c.path)
+)`
+
+	// lockWaitsQuery returns the number of blocked backends, the depth of
+	// the deepest blocking chain and the longest lock wait. The placeholder
+	// is filled with the longest wait expression of the server version.
+	lockWaitsQuery = lockWaitsCTE + `
+SELECT (SELECT count(*) FROM blocked WHERE cardinality(blockers) > 0)::float8
+     , (SELECT COALESCE(max(depth), 0) FROM chains)::float8
+     , %s /*postgres_exporter*/`
+
+	// pg_locks.waitstart was added in PostgreSQL 14
+	lockWaitsLongest14 = `(SELECT COALESCE(EXTRACT(EPOCH FROM now() - min(waitstart)), 0) FROM pg_locks WHERE NOT granted)::float8`
+	lockWaitsLongest   = `NULL::float8`
+
+	// lockWaitsRootBlockersQuery returns the top $1 root blockers by number
+	// of backends waiting on them, directly or not
+	lockWaitsRootBlockersQuery = lockWaitsCTE + `
+SELECT c.root
+     , COALESCE(a.usename, '')
+     , COALESCE(a.application_name, '')
+     , COALESCE(a.state, '')
+     , count(DISTINCT c.pid)::float8
+     , EXTRACT(EPOCH FROM now() - a.xact_start)::float8
+  FROM chains c
+  LEFT JOIN pg_stat_activity a ON a.pid = c.root
+ GROUP BY c.root, a.usename, a.application_name, a.state, a.xact_start
+ ORDER BY 5 DESC
+ LIMIT $1 /*postgres_exporter*/`
+
+	// pg_blocking_pids() was added in PostgreSQL 9.6
+	lockWaitsVersion          = 90600
+	lockWaitsWaitStartVersion = 140000
+
+	// DefaultLockWaitsLimit is the default number of root blockers exposed
+	// by the lock_waits collector
+	DefaultLockWaitsLimit = 5
+)
+
+type lockWaitsScraper struct {
+	limit int
+
+	blockedBackends    *prometheus.Desc
+	maxChainDepth      *prometheus.Desc
+	longestWait        *prometheus.Desc
+	rootBlockerBlocked *prometheus.Desc
+	rootBlockerXactAge *prometheus.Desc
+}
+
+// NewLockWaitsScraper returns a new Scraper exposing the backends waiting on
+// locks held by other backends, and the top limit backends at the root of
+// the blocking chains
+func NewLockWaitsScraper(limit int) Scraper {
+	labels := []string{"pid", "usename", "application_name", "state"}
+	return &lockWaitsScraper{
+		limit: limit,
+		blockedBackends: prometheus.NewDesc(
+			"postgres_lock_waits_blocked_backends",
+			"Number of backends waiting on a lock held by another backend",
+			nil,
+			nil,
+		),
+		maxChainDepth: prometheus.NewDesc(
+			"postgres_lock_waits_max_chain_depth",
+			"Depth of the deepest blocking chain, 1 when the blocked backends wait on a backend that is not blocked itself",
+			nil,
+			nil,
+		),
+		longestWait: prometheus.NewDesc(
+			"postgres_lock_waits_longest_wait_seconds",
+			"Time elapsed since the oldest lock wait started, 0 when no backend waits (PostgreSQL 14+)",
+			nil,
+			nil,
+		),
+		rootBlockerBlocked: prometheus.NewDesc(
+			"postgres_lock_waits_root_blocker_blocked_backends",
+			"Number of backends waiting, directly or not, on a backend that is not blocked itself. pid is 0 for prepared transactions",
+			labels,
+			nil,
+		),
+		rootBlockerXactAge: prometheus.NewDesc(
+			"postgres_lock_waits_root_blocker_xact_age_seconds",
+			"Time elapsed since the transaction of a backend at the root of a blocking chain started",
+			labels,
+			nil,
+		),
+	}
+}
+
+func (*lockWaitsScraper) Name() string {
+	return "LockWaitsScraper"
+}
+
+func (c *lockWaitsScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.blockedBackends
+	ch <- c.maxChainDepth
+	ch <- c.longestWait
+	ch <- c.rootBlockerBlocked
+	ch <- c.rootBlockerXactAge
+}
+
+func (c *lockWaitsScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(lockWaitsVersion) {
+		return nil
+	}
+
+	longest := lockWaitsLongest
+	if version.Gte(lockWaitsWaitStartVersion) {
+		longest = lockWaitsLongest14
+	}
+
+	var blockedBackends, maxChainDepth float64
+	// NULL before PostgreSQL 14
+	var longestWait *float64
+
+	if err := conn.QueryRow(ctx, fmt.Sprintf(lockWaitsQuery, longest)).
+		Scan(&blockedBackends,
+			&maxChainDepth,
+			&longestWait,
+		); err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.blockedBackends, prometheus.GaugeValue, blockedBackends)
+	ch <- prometheus.MustNewConstMetric(c.maxChainDepth, prometheus.GaugeValue, maxChainDepth)
+	emitGauge(ch, c.longestWait, longestWait, nil)
+
+	if blockedBackends == 0 {
+		return nil
+	}
+
+	return c.scrapeRootBlockers(ctx, conn, ch)
+}
+
+// scrapeRootBlockers scrapes the top root blockers.
+func (c *lockWaitsScraper) scrapeRootBlockers(ctx context.Context, conn *pgx.Conn, ch chan<- prometheus.Metric) error {
+	rows, err := conn.Query(ctx, lockWaitsRootBlockersQuery, c.limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var pid int32
+	var usename, applicationName, state string
+	var blocked float64
+	// NULL for prepared transactions
+	var xactAge *float64
+
+	for rows.Next() {
+		if err := rows.Scan(&pid,
+			&usename,
+			&applicationName,
+			&state,
+			&blocked,
+			&xactAge,
+		); err != nil {
+			return err
+		}
+
+		labels := []string{strconv.Itoa(int(pid)), usename, applicationName, state}
+
+		ch <- prometheus.MustNewConstMetric(c.rootBlockerBlocked, prometheus.GaugeValue, blocked, labels...)
+		emitGauge(ch, c.rootBlockerXactAge, xactAge, labels)
+	}
+
+	return rows.Err()
+}
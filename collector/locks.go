@@ -41,6 +41,10 @@ func (*locksScraper) Name() string {
 	return "LocksScraper"
 }
 
+func (c *locksScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.locks
+}
+
 func (c *locksScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
 	rows, err := conn.Query(ctx, locksQuery)
 	if err != nil {
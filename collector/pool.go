@@ -0,0 +1,398 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stalePoolTimeout is how long a pool can go without being used before it is
+// closed, e.g. after a database was dropped or a probe target went away.
+const stalePoolTimeout = 15 * time.Minute
+
+// slotRetryInterval is how often a new connection waiting for a slot tries
+// again to close an idle connection of another pool.
+const slotRetryInterval = 100 * time.Millisecond
+
+// PoolOptions configures the connection pools kept by Pools.
+type PoolOptions struct {
+	// MaxConns is the maximum number of connections of each pool.
+	MaxConns int32
+	// MaxConnIdleTime is the duration after which an idle connection is closed.
+	MaxConnIdleTime time.Duration
+	// HealthCheckPeriod is the duration between health checks of idle connections.
+	HealthCheckPeriod time.Duration
+	// MaxConnections caps the number of connections open at the same time
+	// across every pool, idle or not. Zero means no limit.
+	MaxConnections int
+}
+
+// poolKey identifies a pool in the metrics it exposes.
+type poolKey struct {
+	server   string
+	user     string
+	database string
+}
+
+type managedPool struct {
+	pool       *pgxpool.Pool
+	connString string
+	lastUsed   time.Time
+}
+
+// Pools keeps a long-lived pgxpool.Pool per server and database. Unlike the
+// Exporter, it outlives scrape requests, so connections are reused across
+// scrapes instead of being opened and closed every time.
+type Pools struct {
+	mu    sync.Mutex
+	opts  PoolOptions
+	pools map[poolKey]*managedPool
+	// slots limits the connections open at once, nil when unlimited. A slot
+	// is taken before dialing the server and returned when the socket closes.
+	slots chan struct{}
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	newConnsCount        *prometheus.Desc
+	maxIdleDestroyed     *prometheus.Desc
+	maxLifetimeDestroyed *prometheus.Desc
+}
+
+// Verify Pools satisfies the prometheus.Collector interface
+var _ prometheus.Collector = (*Pools)(nil)
+
+// NewPools returns an empty set of pools, which are created on demand by Acquire.
+func NewPools(opts PoolOptions) *Pools {
+	labels := []string{"server", "user", "datname"}
+
+	var slots chan struct{}
+	if opts.MaxConnections > 0 {
+		slots = make(chan struct{}, opts.MaxConnections)
+	}
+
+	return &Pools{
+		opts:  opts,
+		pools: make(map[poolKey]*managedPool),
+		slots: slots,
+		acquiredConns: prometheus.NewDesc(
+			"postgres_exporter_pool_acquired_connections",
+			"Number of currently acquired connections in the pool",
+			labels,
+			nil,
+		),
+		idleConns: prometheus.NewDesc(
+			"postgres_exporter_pool_idle_connections",
+			"Number of currently idle connections in the pool",
+			labels,
+			nil,
+		),
+		totalConns: prometheus.NewDesc(
+			"postgres_exporter_pool_total_connections",
+			"Total number of connections currently in the pool, including the ones being established",
+			labels,
+			nil,
+		),
+		maxConns: prometheus.NewDesc(
+			"postgres_exporter_pool_max_connections",
+			"Maximum size of the pool",
+			labels,
+			nil,
+		),
+		acquireCount: prometheus.NewDesc(
+			"postgres_exporter_pool_acquires_total",
+			"Number of successful acquires from the pool",
+			labels,
+			nil,
+		),
+		acquireDuration: prometheus.NewDesc(
+			"postgres_exporter_pool_acquire_duration_seconds_total",
+			"Total time spent on successful acquires from the pool",
+			labels,
+			nil,
+		),
+		emptyAcquireCount: prometheus.NewDesc(
+			"postgres_exporter_pool_empty_acquires_total",
+			"Number of successful acquires that waited for a connection because the pool was empty",
+			labels,
+			nil,
+		),
+		canceledAcquireCount: prometheus.NewDesc(
+			"postgres_exporter_pool_canceled_acquires_total",
+			"Number of acquires canceled by a context",
+			labels,
+			nil,
+		),
+		newConnsCount: prometheus.NewDesc(
+			"postgres_exporter_pool_new_connections_total",
+			"Number of new connections opened by the pool",
+			labels,
+			nil,
+		),
+		maxIdleDestroyed: prometheus.NewDesc(
+			"postgres_exporter_pool_max_idle_destroyed_total",
+			"Number of connections closed because they were idle for longer than the idle timeout",
+			labels,
+			nil,
+		),
+		maxLifetimeDestroyed: prometheus.NewDesc(
+			"postgres_exporter_pool_max_lifetime_destroyed_total",
+			"Number of connections closed because they reached their maximum lifetime",
+			labels,
+			nil,
+		),
+	}
+}
+
+// PoolConn is a connection borrowed from Pools.
+type PoolConn struct {
+	conn *pgxpool.Conn
+}
+
+// Conn returns the underlying connection.
+func (c *PoolConn) Conn() *pgx.Conn {
+	return c.conn.Conn()
+}
+
+// Release returns the connection to its pool. It must be called exactly once.
+func (c *PoolConn) Release() {
+	c.conn.Release()
+}
+
+// Acquire returns a connection to the server and database of connConfig,
+// creating the pool on first use. When a new connection is needed and
+// MaxConnections connections are already open, it closes an idle connection
+// of another pool, or waits for one to be released. The connection must be
+// released by the caller.
+func (p *Pools) Acquire(ctx context.Context, connConfig *pgx.ConnConfig) (*PoolConn, error) {
+	pool, err := p.pool(connConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PoolConn{conn: conn}, nil
+}
+
+// slotConn is a connection to the server holding a slot until it is closed.
+type slotConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close closes the connection and returns its slot.
+func (c *slotConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// dialFunc wraps dial to hold a slot for every connection open to the
+// server, including the ones failing later during the startup.
+func (p *Pools) dialFunc(dial pgconn.DialFunc) pgconn.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if err := p.takeSlot(ctx); err != nil {
+			return nil, err
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+
+		return &slotConn{Conn: conn, release: func() { <-p.slots }}, nil
+	}
+}
+
+// takeSlot waits for a free slot. Idle connections of every pool hold their
+// slot, so while none is free it closes the idle connection of the least
+// recently used pool, otherwise no new connection could ever be opened once
+// the pools are warm.
+func (p *Pools) takeSlot(ctx context.Context) error {
+	for {
+		select {
+		case p.slots <- struct{}{}:
+			return nil
+		default:
+		}
+
+		p.closeIdleConn(ctx)
+
+		select {
+		case p.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(slotRetryInterval):
+		}
+	}
+}
+
+// closeIdleConn closes an idle connection of the least recently used pool
+// having one, freeing its slot.
+func (p *Pools) closeIdleConn(ctx context.Context) {
+	p.mu.Lock()
+	pools := make([]*managedPool, 0, len(p.pools))
+	for _, mp := range p.pools {
+		pools = append(pools, mp)
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].lastUsed.Before(pools[j].lastUsed)
+	})
+	p.mu.Unlock()
+
+	for _, mp := range pools {
+		if mp.pool.Stat().IdleConns() == 0 {
+			continue
+		}
+
+		conns := mp.pool.AcquireAllIdle(ctx)
+		for i, conn := range conns {
+			if i == 0 {
+				// a closed connection is destroyed by the pool on release
+				_ = conn.Conn().Close(ctx)
+			}
+			conn.Release()
+		}
+
+		if len(conns) > 0 {
+			return
+		}
+	}
+}
+
+// pool returns the pool for connConfig, creating it when needed.
+func (p *Pools) pool(connConfig *pgx.ConnConfig) (*pgxpool.Pool, error) {
+	key := poolKey{
+		server:   net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port))),
+		user:     connConfig.User,
+		database: connConfig.Database,
+	}
+	connString := connConfig.ConnString()
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closeStaleLocked(now)
+
+	if mp, ok := p.pools[key]; ok {
+		if mp.connString == connString {
+			mp.lastUsed = now
+			return mp.pool, nil
+		}
+		// same server, user and database but different settings (e.g. a new
+		// password), replace the pool
+		go mp.pool.Close()
+		delete(p.pools, key)
+	}
+
+	// ParseConfig is required to build a pgxpool.Config, the connection
+	// settings are then replaced with our own
+	poolConfig, err := pgxpool.ParseConfig("")
+	if err != nil {
+		return nil, fmt.Errorf("error parse pool config: %w", err)
+	}
+	poolConfig.ConnConfig = connConfig.Copy()
+	poolConfig.MaxConns = p.opts.MaxConns
+	poolConfig.MaxConnIdleTime = p.opts.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = p.opts.HealthCheckPeriod
+	if p.slots != nil {
+		poolConfig.ConnConfig.DialFunc = p.dialFunc(poolConfig.ConnConfig.DialFunc)
+	}
+
+	// NewWithConfig does not establish any connection, they are created
+	// lazily on Acquire
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating pool: %w", err)
+	}
+
+	p.pools[key] = &managedPool{
+		pool:       pool,
+		connString: connString,
+		lastUsed:   now,
+	}
+
+	return pool, nil
+}
+
+// closeStaleLocked closes the pools that have not been used recently. p.mu
+// must be held.
+func (p *Pools) closeStaleLocked(now time.Time) {
+	for key, mp := range p.pools {
+		if now.Sub(mp.lastUsed) > stalePoolTimeout {
+			go mp.pool.Close()
+			delete(p.pools, key)
+		}
+	}
+}
+
+// Close closes every pool and their connections.
+func (p *Pools) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, mp := range p.pools {
+		mp.pool.Close()
+		delete(p.pools, key)
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (p *Pools) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.acquiredConns
+	ch <- p.idleConns
+	ch <- p.totalConns
+	ch <- p.maxConns
+	ch <- p.acquireCount
+	ch <- p.acquireDuration
+	ch <- p.emptyAcquireCount
+	ch <- p.canceledAcquireCount
+	ch <- p.newConnsCount
+	ch <- p.maxIdleDestroyed
+	ch <- p.maxLifetimeDestroyed
+}
+
+// Collect implements the prometheus.Collector interface.
+func (p *Pools) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, mp := range p.pools {
+		stat := mp.pool.Stat()
+		labels := []string{key.server, key.user, key.database}
+
+		ch <- prometheus.MustNewConstMetric(p.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(p.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.newConnsCount, prometheus.CounterValue, float64(stat.NewConnsCount()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.maxIdleDestroyed, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()), labels...)
+		ch <- prometheus.MustNewConstMetric(p.maxLifetimeDestroyed, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()), labels...)
+	}
+}
@@ -0,0 +1,289 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scope defines which connection a scraper runs against.
+type scope int
+
+const (
+	// scopeServer scrapers run once per scrape on the server connection.
+	scopeServer scope = iota
+	// scopeDatabase scrapers run once per database returned by listDatnameQuery.
+	scopeDatabase
+)
+
+// registration describes a scraper known to the exporter.
+type registration struct {
+	name           string
+	scope          scope
+	defaultEnabled bool
+	newScraper     func() Scraper
+	// newConfiguredScraper replaces newScraper for scrapers with settings
+	newConfiguredScraper func(Config) Scraper
+}
+
+// build returns a new instance of the scraper.
+func (r registration) build(c Config) Scraper {
+	if r.newConfiguredScraper != nil {
+		return r.newConfiguredScraper(c)
+	}
+	return r.newScraper()
+}
+
+// registry lists every scraper the exporter knows about. The name is used to
+// generate the --collector.<name> flags, so it must be stable.
+var registry = []registration{
+	{name: "connections", scope: scopeServer, defaultEnabled: true, newScraper: NewConnectionsScraper},
+	{name: "database_wraparound", scope: scopeServer, defaultEnabled: true, newScraper: NewDatabaseWraparoundScraper},
+	{name: "disk_usage", scope: scopeDatabase, defaultEnabled: true, newScraper: NewDiskUsageScraper},
+	{name: "index_health", scope: scopeDatabase, defaultEnabled: false, newScraper: NewIndexHealthScraper},
+	{name: "info", scope: scopeServer, defaultEnabled: true, newScraper: NewInfoScraper},
+	{name: "lock_waits", scope: scopeServer, defaultEnabled: true, newConfiguredScraper: func(c Config) Scraper {
+		return NewLockWaitsScraper(c.LockWaitsLimit)
+	}},
+	{name: "locks", scope: scopeServer, defaultEnabled: true, newScraper: NewLocksScraper},
+	{name: "replication_slots", scope: scopeServer, defaultEnabled: true, newScraper: NewReplicationSlotsScraper},
+	{name: "settings", scope: scopeServer, defaultEnabled: false, newConfiguredScraper: func(c Config) Scraper {
+		return NewSettingsScraper(c.SettingsAllowlist)
+	}},
+	{name: "stat_activity", scope: scopeServer, defaultEnabled: true, newConfiguredScraper: func(c Config) Scraper {
+		return NewStatActivityScraper(c.StatActivityUsenames, c.StatActivityApplicationNames, c.StatActivityLimit)
+	}},
+	{name: "stat_archiver", scope: scopeServer, defaultEnabled: true, newScraper: NewStatArchiverScraper},
+	{name: "stat_bgwriter", scope: scopeServer, defaultEnabled: true, newScraper: NewStatBgwriterScraper},
+	{name: "stat_database", scope: scopeServer, defaultEnabled: true, newScraper: NewStatDatabaseScraper},
+	{name: "stat_io", scope: scopeServer, defaultEnabled: true, newScraper: NewStatIOScraper},
+	{name: "stat_progress_vacuum", scope: scopeDatabase, defaultEnabled: true, newScraper: NewStatVacuumProgressScraper},
+	{name: "stat_replication", scope: scopeServer, defaultEnabled: true, newScraper: NewStatReplicationScraper},
+	{name: "stat_statements", scope: scopeServer, defaultEnabled: false, newConfiguredScraper: func(c Config) Scraper {
+		return NewStatStatementsScraper(c.StatStatementsLimit)
+	}},
+	{name: "stat_user_indexes", scope: scopeDatabase, defaultEnabled: true, newScraper: NewStatUserIndexesScraper},
+	{name: "stat_user_tables", scope: scopeDatabase, defaultEnabled: true, newScraper: NewStatUserTablesScraper},
+	{name: "stat_wal", scope: scopeServer, defaultEnabled: true, newScraper: NewStatWalScraper},
+	{name: "stat_wal_receiver", scope: scopeServer, defaultEnabled: true, newScraper: NewStatWalReceiverScraper},
+	{name: "table_bloat", scope: scopeDatabase, defaultEnabled: false, newConfiguredScraper: func(c Config) Scraper {
+		return NewTableBloatScraper(c.logger, c.TableBloatPgstattuple, c.TableBloatPgstattupleLimit, c.TableBloatPgstattupleInterval)
+	}},
+}
+
+// ScraperInfo describes a registered scraper, so callers can build flags for it.
+type ScraperInfo struct {
+	Name           string
+	PerDatabase    bool
+	DefaultEnabled bool
+}
+
+// Scrapers returns the scrapers known to the exporter.
+func Scrapers() []ScraperInfo {
+	scrapers := make([]ScraperInfo, 0, len(registry))
+	for _, r := range registry {
+		scrapers = append(scrapers, ScraperInfo{
+			Name:           r.name,
+			PerDatabase:    r.scope == scopeDatabase,
+			DefaultEnabled: r.defaultEnabled,
+		})
+	}
+	return scrapers
+}
+
+// Config holds the settings used to build an Exporter.
+type Config struct {
+	ExcludedDatabases []string
+	// Collectors maps a scraper name to its enabled state. Scrapers missing
+	// from the map fall back to their default state.
+	Collectors map[string]bool
+	// Concurrency is the maximum number of global scrapers and databases
+	// scraped at the same time.
+	Concurrency int
+	// ScraperTimeout is the default time limit of each scraper run. Zero means
+	// scrapers are only bounded by the scrape request deadline.
+	ScraperTimeout time.Duration
+	// ScraperTimeouts overrides ScraperTimeout by scraper name.
+	ScraperTimeouts map[string]time.Duration
+	// CustomQueries are user-defined queries, run next to the registered
+	// scrapers.
+	CustomQueries []CustomQuery
+	// StatStatementsLimit is the number of statements exposed by the
+	// stat_statements collector, by total execution time, the others are
+	// folded into one series per database and user.
+	StatStatementsLimit int
+	// TableBloatPgstattuple makes the table_bloat collector measure bloat
+	// with pgstattuple_approx() in the databases where pgstattuple is
+	// installed, for the TableBloatPgstattupleLimit largest tables. The
+	// measures are reused for TableBloatPgstattupleInterval.
+	TableBloatPgstattuple         bool
+	TableBloatPgstattupleLimit    int
+	TableBloatPgstattupleInterval time.Duration
+	// SettingsAllowlist are the string settings exposed by the settings
+	// collector.
+	SettingsAllowlist []string
+	// LockWaitsLimit is the number of root blockers exposed by the
+	// lock_waits collector.
+	LockWaitsLimit int
+	// StatActivityUsenames and StatActivityApplicationNames are the users
+	// and applications the stat_activity collector counts connections for.
+	// When empty, the top StatActivityLimit ones are counted.
+	StatActivityUsenames         []string
+	StatActivityApplicationNames []string
+	StatActivityLimit            int
+
+	// logger is given to the scrapers logging errors that do not fail them,
+	// set by NewExporter
+	logger *slog.Logger
+}
+
+// Validate returns an error when the config references unknown scrapers.
+func (c Config) Validate() error {
+	for name := range c.Collectors {
+		if !isRegistered(name) {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	for name, timeout := range c.ScraperTimeouts {
+		if !isRegistered(name) {
+			return fmt.Errorf("timeout for unknown collector %q", name)
+		}
+		if timeout < 0 {
+			return fmt.Errorf("negative timeout for collector %q", name)
+		}
+	}
+	if c.StatStatementsLimit < 1 {
+		return errors.New("stat_statements limit must be positive")
+	}
+	if c.TableBloatPgstattupleLimit < 1 {
+		return errors.New("table_bloat pgstattuple limit must be positive")
+	}
+	if c.TableBloatPgstattupleInterval < 0 {
+		return errors.New("table_bloat pgstattuple interval must not be negative")
+	}
+	if c.LockWaitsLimit < 1 {
+		return errors.New("lock_waits limit must be positive")
+	}
+	if c.StatActivityLimit < 1 {
+		return errors.New("stat_activity limit must be positive")
+	}
+	return c.validateMetricNames()
+}
+
+// descCollector is a prometheus.Collector only describing metrics, to detect
+// metric names exposed twice with a registry.
+type descCollector func(ch chan<- *prometheus.Desc)
+
+func (d descCollector) Describe(ch chan<- *prometheus.Desc) {
+	d(ch)
+}
+
+func (descCollector) Collect(chan<- prometheus.Metric) {}
+
+// validateMetricNames returns an error when a custom query exposes a metric
+// already exposed by the exporter or by an enabled scraper, which would fail
+// every scrape. The other metrics served on /metrics use the prefixes
+// rejected when the custom queries are loaded.
+func (c Config) validateMetricNames() error {
+	metrics := prometheus.NewRegistry()
+	builtin := descCollector(func(ch chan<- *prometheus.Desc) {
+		ch <- upDesc
+		ch <- databaseUpDesc
+		ch <- infoDesc
+		ch <- scrapeDurationDesc
+		ch <- scrapeSuccessDesc
+		ch <- scrapeTimeoutDesc
+		for _, r := range registry {
+			if c.isEnabled(r) {
+				r.build(c).Describe(ch)
+			}
+		}
+	})
+	if err := metrics.Register(builtin); err != nil {
+		return err
+	}
+
+	for _, q := range c.CustomQueries {
+		if err := metrics.Register(descCollector(NewCustomQueryScraper(q).Describe)); err != nil {
+			return fmt.Errorf("custom query %q: metric name already in use: %w", q.Name, err)
+		}
+	}
+	return nil
+}
+
+// isEnabled reports whether the given scraper should run.
+func (c Config) isEnabled(r registration) bool {
+	if enabled, ok := c.Collectors[r.name]; ok {
+		return enabled
+	}
+	return r.defaultEnabled
+}
+
+// timeout returns the time limit of the given scraper.
+func (c Config) timeout(r registration) time.Duration {
+	if timeout, ok := c.ScraperTimeouts[r.name]; ok {
+		return timeout
+	}
+	return c.ScraperTimeout
+}
+
+// enabledScraper is a scraper enabled in the Config, with its settings.
+type enabledScraper struct {
+	Scraper
+	timeout time.Duration
+}
+
+// enabledScrapers builds the enabled scrapers, custom queries included, for
+// the given scope.
+func (c Config) enabledScrapers(s scope) []enabledScraper {
+	var scrapers []enabledScraper
+	for _, r := range registry {
+		if r.scope == s && c.isEnabled(r) {
+			scrapers = append(scrapers, enabledScraper{
+				Scraper: r.build(c),
+				timeout: c.timeout(r),
+			})
+		}
+	}
+
+	for _, q := range c.CustomQueries {
+		if (q.Scope == customScopeDatabase) != (s == scopeDatabase) {
+			continue
+		}
+
+		timeout := c.ScraperTimeout
+		if q.Timeout > 0 {
+			timeout = q.Timeout
+		}
+		scrapers = append(scrapers, enabledScraper{
+			Scraper: NewCustomQueryScraper(q),
+			timeout: timeout,
+		})
+	}
+
+	return scrapers
+}
+
+// databaseFilter is implemented by per-database scrapers that only run on
+// some databases.
+type databaseFilter interface {
+	runsOn(datname string) bool
+}
+
+// runsOn reports whether the per-database scraper runs on the database.
+func runsOn(scraper Scraper, datname string) bool {
+	filter, ok := scraper.(databaseFilter)
+	return !ok || filter.runsOn(datname)
+}
+
+func isRegistered(name string) bool {
+	for _, r := range registry {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
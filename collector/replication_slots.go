@@ -0,0 +1,313 @@
+package collector
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Scrape query for PostgreSQL 13+. The current LSN is the last received
+	// one on standbys.
+	replicationSlots13 = `
+WITH current AS (
+  SELECT CASE WHEN pg_is_in_recovery()
+              THEN pg_last_wal_receive_lsn()
+              ELSE pg_current_wal_lsn()
+         END AS lsn
+)
+SELECT slot_name
+     , slot_type
+     , COALESCE(plugin, '')
+     , COALESCE(database, '')
+     , active
+     , pg_wal_lsn_diff(current.lsn, restart_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, confirmed_flush_lsn)::float8
+     , wal_status
+     , safe_wal_size::float8
+  FROM pg_replication_slots, current /*postgres_exporter*/`
+
+	// Scrape query for PostgreSQL 10 to 12, which have no wal_status and
+	// safe_wal_size
+	replicationSlots10 = `
+WITH current AS (
+  SELECT CASE WHEN pg_is_in_recovery()
+              THEN pg_last_wal_receive_lsn()
+              ELSE pg_current_wal_lsn()
+         END AS lsn
+)
+SELECT slot_name
+     , slot_type
+     , COALESCE(plugin, '')
+     , COALESCE(database, '')
+     , active
+     , pg_wal_lsn_diff(current.lsn, restart_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, confirmed_flush_lsn)::float8
+     , NULL::text AS wal_status
+     , NULL::float8 AS safe_wal_size
+  FROM pg_replication_slots, current /*postgres_exporter*/`
+
+	statReplicationSlots = `
+SELECT slot_name
+     , spill_txns
+     , spill_count
+     , spill_bytes
+     , stream_txns
+     , stream_count
+     , stream_bytes
+     , total_txns
+     , total_bytes
+  FROM pg_stat_replication_slots /*postgres_exporter*/`
+
+	replicationSlotsVersion          = 100000
+	replicationSlotsWalStatusVersion = 130000
+	statReplicationSlotsVersion      = 140000
+)
+
+// walStatuses are the values of pg_replication_slots.wal_status
+var walStatuses = []string{"reserved", "extended", "unreserved", "lost"}
+
+type replicationSlotsScraper struct {
+	active            *prometheus.Desc
+	retainedBytes     *prometheus.Desc
+	confirmedFlushLag *prometheus.Desc
+	walStatus         *prometheus.Desc
+	safeWalSize       *prometheus.Desc
+	spillTxns         *prometheus.Desc
+	spillCount        *prometheus.Desc
+	spillBytes        *prometheus.Desc
+	streamTxns        *prometheus.Desc
+	streamCount       *prometheus.Desc
+	streamBytes       *prometheus.Desc
+	totalTxns         *prometheus.Desc
+	totalBytes        *prometheus.Desc
+}
+
+// NewReplicationSlotsScraper returns a new Scraper exposing PostgreSQL
+// `pg_replication_slots` view, and `pg_stat_replication_slots` on
+// PostgreSQL 14+
+func NewReplicationSlotsScraper() Scraper {
+	labels := []string{"slot_name", "slot_type", "plugin", "datname"}
+	return &replicationSlotsScraper{
+		active: prometheus.NewDesc(
+			"postgres_replication_slot_active",
+			"Whether the slot is currently being used",
+			labels,
+			nil,
+		),
+		retainedBytes: prometheus.NewDesc(
+			"postgres_replication_slot_retained_wal_bytes",
+			"Amount of WAL retained by the slot, from its restart_lsn to the current LSN",
+			labels,
+			nil,
+		),
+		confirmedFlushLag: prometheus.NewDesc(
+			"postgres_replication_slot_confirmed_flush_lag_bytes",
+			"Amount of WAL the consumer of a logical slot has not confirmed yet, from its confirmed_flush_lsn to the current LSN",
+			labels,
+			nil,
+		),
+		walStatus: prometheus.NewDesc(
+			"postgres_replication_slot_wal_status",
+			"Availability of the WAL files claimed by the slot, one of reserved, extended, unreserved or lost",
+			append(labels, "wal_status"),
+			nil,
+		),
+		safeWalSize: prometheus.NewDesc(
+			"postgres_replication_slot_safe_wal_size_bytes",
+			"Amount of WAL that can be written before the slot is in danger of getting lost, when max_slot_wal_keep_size is set",
+			labels,
+			nil,
+		),
+		spillTxns: prometheus.NewDesc(
+			"postgres_stat_replication_slots_spill_txns_total",
+			"Number of transactions spilled to disk once the memory used by logical decoding exceeded logical_decoding_work_mem",
+			[]string{"slot_name"},
+			nil,
+		),
+		spillCount: prometheus.NewDesc(
+			"postgres_stat_replication_slots_spill_count_total",
+			"Number of times transactions were spilled to disk while decoding changes from WAL for this slot",
+			[]string{"slot_name"},
+			nil,
+		),
+		spillBytes: prometheus.NewDesc(
+			"postgres_stat_replication_slots_spill_bytes_total",
+			"Amount of decoded transaction data spilled to disk while decoding changes from WAL for this slot",
+			[]string{"slot_name"},
+			nil,
+		),
+		streamTxns: prometheus.NewDesc(
+			"postgres_stat_replication_slots_stream_txns_total",
+			"Number of in-progress transactions streamed to the decoding output plugin",
+			[]string{"slot_name"},
+			nil,
+		),
+		streamCount: prometheus.NewDesc(
+			"postgres_stat_replication_slots_stream_count_total",
+			"Number of times in-progress transactions were streamed to the decoding output plugin",
+			[]string{"slot_name"},
+			nil,
+		),
+		streamBytes: prometheus.NewDesc(
+			"postgres_stat_replication_slots_stream_bytes_total",
+			"Amount of transaction data decoded for streaming in-progress transactions to the decoding output plugin",
+			[]string{"slot_name"},
+			nil,
+		),
+		totalTxns: prometheus.NewDesc(
+			"postgres_stat_replication_slots_total_txns_total",
+			"Number of decoded transactions sent to the decoding output plugin",
+			[]string{"slot_name"},
+			nil,
+		),
+		totalBytes: prometheus.NewDesc(
+			"postgres_stat_replication_slots_total_bytes_total",
+			"Amount of transaction data decoded for sending transactions to the decoding output plugin",
+			[]string{"slot_name"},
+			nil,
+		),
+	}
+}
+
+func (*replicationSlotsScraper) Name() string {
+	return "ReplicationSlotsScraper"
+}
+
+func (c *replicationSlotsScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.retainedBytes
+	ch <- c.confirmedFlushLag
+	ch <- c.walStatus
+	ch <- c.safeWalSize
+	ch <- c.spillTxns
+	ch <- c.spillCount
+	ch <- c.spillBytes
+	ch <- c.streamTxns
+	ch <- c.streamCount
+	ch <- c.streamBytes
+	ch <- c.totalTxns
+	ch <- c.totalBytes
+}
+
+func (c *replicationSlotsScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(replicationSlotsVersion) {
+		return nil
+	}
+
+	if err := c.scrapeSlots(ctx, conn, version, ch); err != nil {
+		return err
+	}
+
+	if version.Gte(statReplicationSlotsVersion) {
+		return c.scrapeStats(ctx, conn, ch)
+	}
+	return nil
+}
+
+// scrapeSlots scrapes pg_replication_slots.
+func (c *replicationSlotsScraper) scrapeSlots(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	query := replicationSlots10
+	if version.Gte(replicationSlotsWalStatusVersion) {
+		query = replicationSlots13
+	}
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var slotName, slotType, plugin, datname string
+	var active bool
+	// NULL when the slot never reserved WAL, for physical slots, on
+	// PostgreSQL 12 and older, or when max_slot_wal_keep_size is unset
+	var retainedBytes, confirmedFlushLag, safeWalSize *float64
+	var walStatus *string
+
+	for rows.Next() {
+		if err := rows.Scan(&slotName,
+			&slotType,
+			&plugin,
+			&datname,
+			&active,
+			&retainedBytes,
+			&confirmedFlushLag,
+			&walStatus,
+			&safeWalSize,
+		); err != nil {
+			return err
+		}
+
+		labels := []string{slotName, slotType, plugin, datname}
+
+		activeValue := 0.0
+		if active {
+			activeValue = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, activeValue, labels...)
+
+		if retainedBytes != nil {
+			ch <- prometheus.MustNewConstMetric(c.retainedBytes, prometheus.GaugeValue, *retainedBytes, labels...)
+		}
+		if confirmedFlushLag != nil {
+			ch <- prometheus.MustNewConstMetric(c.confirmedFlushLag, prometheus.GaugeValue, *confirmedFlushLag, labels...)
+		}
+		if safeWalSize != nil {
+			ch <- prometheus.MustNewConstMetric(c.safeWalSize, prometheus.GaugeValue, *safeWalSize, labels...)
+		}
+
+		if walStatus != nil {
+			// one series per status, so alerts can match on wal_status="lost"
+			for _, status := range walStatuses {
+				value := 0.0
+				if status == *walStatus {
+					value = 1.0
+				}
+				ch <- prometheus.MustNewConstMetric(c.walStatus, prometheus.GaugeValue, value, append(labels, status)...)
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
+// scrapeStats scrapes pg_stat_replication_slots, PostgreSQL 14+.
+func (c *replicationSlotsScraper) scrapeStats(ctx context.Context, conn *pgx.Conn, ch chan<- prometheus.Metric) error {
+	rows, err := conn.Query(ctx, statReplicationSlots)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var slotName string
+	var spillTxns, spillCount, spillBytes, streamTxns, streamCount,
+		streamBytes, totalTxns, totalBytes int64
+
+	for rows.Next() {
+		if err := rows.Scan(&slotName,
+			&spillTxns,
+			&spillCount,
+			&spillBytes,
+			&streamTxns,
+			&streamCount,
+			&streamBytes,
+			&totalTxns,
+			&totalBytes,
+		); err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.spillTxns, prometheus.CounterValue, float64(spillTxns), slotName)
+		ch <- prometheus.MustNewConstMetric(c.spillCount, prometheus.CounterValue, float64(spillCount), slotName)
+		ch <- prometheus.MustNewConstMetric(c.spillBytes, prometheus.CounterValue, float64(spillBytes), slotName)
+		ch <- prometheus.MustNewConstMetric(c.streamTxns, prometheus.CounterValue, float64(streamTxns), slotName)
+		ch <- prometheus.MustNewConstMetric(c.streamCount, prometheus.CounterValue, float64(streamCount), slotName)
+		ch <- prometheus.MustNewConstMetric(c.streamBytes, prometheus.CounterValue, float64(streamBytes), slotName)
+		ch <- prometheus.MustNewConstMetric(c.totalTxns, prometheus.CounterValue, float64(totalTxns), slotName)
+		ch <- prometheus.MustNewConstMetric(c.totalBytes, prometheus.CounterValue, float64(totalBytes), slotName)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Scrape query
+	settingsQuery = `
+SELECT name
+     , setting
+     , COALESCE(unit, '')
+     , vartype
+     , source
+     , pending_restart
+  FROM pg_settings /*postgres_exporter*/`
+
+	// Scrape query for PostgreSQL 9.4, which has no pending_restart
+	settingsQuery94 = `
+SELECT name
+     , setting
+     , COALESCE(unit, '')
+     , vartype
+     , source
+     , false AS pending_restart
+  FROM pg_settings /*postgres_exporter*/`
+
+	settingsPendingRestartVersion = 90500
+)
+
+// DefaultSettingsAllowlist are the string settings exposed by default with
+// postgres_settings_info
+var DefaultSettingsAllowlist = []string{
+	"archive_mode",
+	"synchronous_commit",
+	"synchronous_standby_names",
+	"wal_level",
+}
+
+type settingsScraper struct {
+	allowlist      map[string]struct{}
+	value          *prometheus.Desc
+	pendingRestart *prometheus.Desc
+	info           *prometheus.Desc
+}
+
+// NewSettingsScraper returns a new Scraper exposing the numeric and boolean
+// settings of PostgreSQL `pg_settings` view in bytes and seconds, and the
+// string settings in allowlist as labels of an info metric
+func NewSettingsScraper(allowlist []string) Scraper {
+	names := make(map[string]struct{}, len(allowlist))
+	for _, name := range allowlist {
+		names[name] = struct{}{}
+	}
+
+	return &settingsScraper{
+		allowlist: names,
+		value: prometheus.NewDesc(
+			"postgres_settings_value",
+			"Value of a numeric or boolean setting, converted to bytes or seconds when it has a unit. Negative values, which usually disable the feature, are not converted",
+			[]string{"name", "unit", "source"},
+			nil,
+		),
+		pendingRestart: prometheus.NewDesc(
+			"postgres_settings_pending_restart",
+			"Whether the setting was changed in the configuration file but needs a restart to be applied",
+			[]string{"name"},
+			nil,
+		),
+		info: prometheus.NewDesc(
+			"postgres_settings_info",
+			"Value of a string setting, for the settings on the allowlist",
+			[]string{"name", "setting", "source"},
+			nil,
+		),
+	}
+}
+
+func (*settingsScraper) Name() string {
+	return "SettingsScraper"
+}
+
+func (c *settingsScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.value
+	ch <- c.pendingRestart
+	ch <- c.info
+}
+
+func (c *settingsScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	query := settingsQuery94
+	if version.Gte(settingsPendingRestartVersion) {
+		query = settingsQuery
+	}
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var name, setting, unit, vartype, source string
+	var pendingRestart bool
+
+	for rows.Next() {
+		if err := rows.Scan(&name,
+			&setting,
+			&unit,
+			&vartype,
+			&source,
+			&pendingRestart,
+		); err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.pendingRestart, prometheus.GaugeValue, boolValue(pendingRestart), name)
+
+		switch vartype {
+		case "bool":
+			ch <- prometheus.MustNewConstMetric(c.value, prometheus.GaugeValue, boolValue(setting == "on"), name, "", source)
+		case "integer", "real":
+			value, err := strconv.ParseFloat(setting, 64)
+			if err != nil {
+				// the server never returns malformed numbers
+				continue
+			}
+			value, baseUnit := normalizeSetting(value, unit)
+			ch <- prometheus.MustNewConstMetric(c.value, prometheus.GaugeValue, value, name, baseUnit, source)
+		default:
+			// string and enum settings
+			if _, ok := c.allowlist[name]; ok {
+				ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, infoMetricValue, name, setting, source)
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
+// normalizeSetting converts a setting in the given pg_settings unit, e.g.
+// "8kB" or "ms", to bytes or seconds. Negative values keep their unconverted
+// value, and unknown units are returned as is.
+func normalizeSetting(value float64, unit string) (float64, string) {
+	// block sized units are prefixed with the block size, e.g. "8kB" or "16MB"
+	base := strings.TrimLeft(unit, "0123456789")
+	multiplier := 1.0
+	if prefix := unit[:len(unit)-len(base)]; prefix != "" {
+		n, err := strconv.ParseFloat(prefix, 64)
+		if err != nil {
+			return value, unit
+		}
+		multiplier = n
+	}
+
+	var baseUnit string
+	switch base {
+	case "":
+		return value, ""
+	case "B":
+		baseUnit = "bytes"
+	case "kB":
+		baseUnit, multiplier = "bytes", multiplier*(1<<10)
+	case "MB":
+		baseUnit, multiplier = "bytes", multiplier*(1<<20)
+	case "GB":
+		baseUnit, multiplier = "bytes", multiplier*(1<<30)
+	case "TB":
+		baseUnit, multiplier = "bytes", multiplier*(1<<40)
+	case "us":
+		baseUnit, multiplier = "seconds", multiplier/1e6
+	case "ms":
+		baseUnit, multiplier = "seconds", multiplier/1e3
+	case "s":
+		baseUnit = "seconds"
+	case "min":
+		baseUnit, multiplier = "seconds", multiplier*60
+	case "h":
+		baseUnit, multiplier = "seconds", multiplier*60*60
+	case "d":
+		baseUnit, multiplier = "seconds", multiplier*24*60*60
+	default:
+		return value, unit
+	}
+
+	// -1 usually disables the feature, keep it recognizable
+	if value < 0 {
+		return value, baseUnit
+	}
+	return value * multiplier, baseUnit
+}
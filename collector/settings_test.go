@@ -0,0 +1,40 @@
+package collector
+
+import "testing"
+
+func TestNormalizeSetting(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		unit     string
+		want     float64
+		wantUnit string
+	}{
+		{name: "shared_buffers", value: 16384, unit: "8kB", want: 16384 * 8192, wantUnit: "bytes"},
+		{name: "wal_segment_size", value: 1, unit: "16MB", want: 16 << 20, wantUnit: "bytes"},
+		{name: "work_mem", value: 4096, unit: "kB", want: 4096 << 10, wantUnit: "bytes"},
+		{name: "max_wal_size", value: 1024, unit: "MB", want: 1024 << 20, wantUnit: "bytes"},
+		{name: "vacuum_cost_delay", value: 2000, unit: "us", want: 0.002, wantUnit: "seconds"},
+		{name: "statement_timeout", value: 1500, unit: "ms", want: 1.5, wantUnit: "seconds"},
+		{name: "checkpoint_timeout", value: 300, unit: "s", want: 300, wantUnit: "seconds"},
+		{name: "log_rotation_age", value: 1440, unit: "min", want: 86400, wantUnit: "seconds"},
+		{name: "hours", value: 2, unit: "h", want: 7200, wantUnit: "seconds"},
+		{name: "days", value: 1, unit: "d", want: 86400, wantUnit: "seconds"},
+		{name: "log_min_duration_statement", value: -1, unit: "ms", want: -1, wantUnit: "seconds"},
+		{name: "effective_io_concurrency", value: 1, unit: "", want: 1, wantUnit: ""},
+		{name: "unknown unit", value: 3, unit: "parsecs", want: 3, wantUnit: "parsecs"},
+		{name: "unknown unit with block size", value: 3, unit: "8parsecs", want: 3, wantUnit: "8parsecs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotUnit := normalizeSetting(tt.value, tt.unit)
+			if got != tt.want {
+				t.Errorf("normalizeSetting(%v, %q) value = %v, want %v", tt.value, tt.unit, got, tt.want)
+			}
+			if gotUnit != tt.wantUnit {
+				t.Errorf("normalizeSetting(%v, %q) unit = %q, want %q", tt.value, tt.unit, gotUnit, tt.wantUnit)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	pgx "github.com/jackc/pgx/v5"
@@ -71,10 +72,55 @@ SELECT EXTRACT(EPOCH FROM age(clock_timestamp(), coalesce(min(query_start), cloc
 	// There should be no need to coalesce to a non-null value, as this query
 	// itself will hold a snapshot even if no other backends have an xmin.
 	statActivityScraperOldestSnapshotXidQuery = `SELECT min(backend_xmin::text::float) FROM pg_stat_activity /*postgres_exporter*/`
+
+	// Backends by type, state and what they are waiting on
+	statActivityWaitEventQuery = `
+SELECT COALESCE(backend_type, '')
+     , COALESCE(state, '')
+     , COALESCE(wait_event_type, '')
+     , COALESCE(wait_event, '')
+     , count(*)::float
+  FROM pg_stat_activity
+ GROUP BY 1, 2, 3, 4 /*postgres_exporter*/`
+
+	// Client connections by the column in the placeholder. The values in the
+	// $1 allowlist, or the top $2 values by connections when it is empty,
+	// keep their own series, the others are folded into "other".
+	statActivityTopQuery = `
+WITH counts AS (
+  SELECT COALESCE(%s, '') AS name
+       , count(*) AS count
+    FROM pg_stat_activity
+   WHERE backend_type = 'client backend'
+   GROUP BY 1
+), ranked AS (
+  SELECT name
+       , count
+       , CASE WHEN COALESCE(cardinality($1::text[]), 0) > 0
+              THEN name = ANY($1::text[])
+              ELSE row_number() OVER (ORDER BY count DESC, name) <= $2
+         END AS kept
+    FROM counts
+)
+SELECT CASE WHEN kept THEN name ELSE 'other' END
+     , sum(count)::float
+  FROM ranked
+ GROUP BY 1 /*postgres_exporter*/`
+
+	// DefaultStatActivityLimit is the default number of users and
+	// applications exposed by the stat_activity collector
+	DefaultStatActivityLimit = 10
 )
 
 type statActivityScraper struct {
+	usenames         []string
+	applicationNames []string
+	limit            int
+
 	connections *prometheus.Desc
+	waitEvents  *prometheus.Desc
+	usename     *prometheus.Desc
+	application *prometheus.Desc
 	backend     *prometheus.Desc
 	xact        *prometheus.Desc
 	active      *prometheus.Desc
@@ -82,15 +128,38 @@ type statActivityScraper struct {
 	xmin        *prometheus.Desc
 }
 
-// NewStatActivityScraper returns a new Scraper exposing postgres pg_stat_activity
-func NewStatActivityScraper() Scraper {
+// NewStatActivityScraper returns a new Scraper exposing postgres pg_stat_activity.
+// Client connections are counted for the users and applications in usenames
+// and applicationNames, or for the top limit ones when empty
+func NewStatActivityScraper(usenames, applicationNames []string, limit int) Scraper {
 	return &statActivityScraper{
+		usenames:         usenames,
+		applicationNames: applicationNames,
+		limit:            limit,
 		connections: prometheus.NewDesc(
 			"postgres_stat_activity_connections",
 			"Number of current connections in their current state",
 			[]string{"datname", "state"},
 			nil,
 		),
+		waitEvents: prometheus.NewDesc(
+			"postgres_stat_activity_wait_event_connections",
+			"Number of backends by type, state and wait event, wait_event_type and wait_event are empty when not waiting",
+			[]string{"backend_type", "state", "wait_event_type", "wait_event"},
+			nil,
+		),
+		usename: prometheus.NewDesc(
+			"postgres_stat_activity_usename_connections",
+			"Number of client connections by user, users not selected are summed into usename=\"other\"",
+			[]string{"usename"},
+			nil,
+		),
+		application: prometheus.NewDesc(
+			"postgres_stat_activity_application_name_connections",
+			"Number of client connections by application, applications not selected are summed into application_name=\"other\"",
+			[]string{"application_name"},
+			nil,
+		),
 		backend: prometheus.NewDesc(
 			"postgres_stat_activity_oldest_backend_timestamp",
 			"The oldest backend started timestamp",
@@ -128,6 +197,18 @@ func (*statActivityScraper) Name() string {
 	return "StatActivityScraper"
 }
 
+func (c *statActivityScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.connections
+	ch <- c.waitEvents
+	ch <- c.usename
+	ch <- c.application
+	ch <- c.backend
+	ch <- c.xact
+	ch <- c.active
+	ch <- c.snapshot
+	ch <- c.xmin
+}
+
 func (c *statActivityScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
 	rows, err := conn.Query(ctx, statActivityQuery)
 	if err != nil {
@@ -195,5 +276,65 @@ func (c *statActivityScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Vers
 	// postgres_stat_activity_oldest_backend_xmin
 	ch <- prometheus.MustNewConstMetric(c.xmin, prometheus.GaugeValue, oldestXmin)
 
-	return nil
+	if err := c.scrapeWaitEvents(ctx, conn, ch); err != nil {
+		return err
+	}
+
+	// postgres_stat_activity_usename_connections
+	if err := c.scrapeTop(ctx, conn, ch, c.usename, "usename", c.usenames); err != nil {
+		return err
+	}
+
+	// postgres_stat_activity_application_name_connections
+	return c.scrapeTop(ctx, conn, ch, c.application, "application_name", c.applicationNames)
+}
+
+// scrapeWaitEvents scrapes the backends by type, state and wait event.
+func (c *statActivityScraper) scrapeWaitEvents(ctx context.Context, conn *pgx.Conn, ch chan<- prometheus.Metric) error {
+	rows, err := conn.Query(ctx, statActivityWaitEventQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var backendType, state, waitEventType, waitEvent string
+	var count float64
+
+	for rows.Next() {
+		if err := rows.Scan(&backendType,
+			&state,
+			&waitEventType,
+			&waitEvent,
+			&count,
+		); err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.waitEvents, prometheus.GaugeValue, count, backendType, state, waitEventType, waitEvent)
+	}
+
+	return rows.Err()
+}
+
+// scrapeTop scrapes the client connections by column, for the values in
+// allowlist or the top ones.
+func (c *statActivityScraper) scrapeTop(ctx context.Context, conn *pgx.Conn, ch chan<- prometheus.Metric, desc *prometheus.Desc, column string, allowlist []string) error {
+	rows, err := conn.Query(ctx, fmt.Sprintf(statActivityTopQuery, column), allowlist, c.limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var name string
+	var count float64
+
+	for rows.Next() {
+		if err := rows.Scan(&name, &count); err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, count, name)
+	}
+
+	return rows.Err()
 }
@@ -51,6 +51,12 @@ func (*statArchiverScraper) Name() string {
 	return "StatArchiverScraper"
 }
 
+func (c *statArchiverScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.archivedCount
+	ch <- c.failedCount
+	ch <- c.statsReset
+}
+
 func (c *statArchiverScraper) Scrape(ctx context.Context, db *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
 	var archivedCount, failedCount int64
 	var statsReset time.Time
@@ -23,6 +23,45 @@ SELECT checkpoints_timed
      , buffers_alloc
      , stats_reset
   FROM pg_stat_bgwriter /*postgres_exporter*/`
+
+	// Scrape queries for PostgreSQL 17+, where the checkpointer columns moved
+	// to pg_stat_checkpointer and the backend ones were removed in favour of
+	// pg_stat_io
+	statBgwriter17 = `
+SELECT buffers_clean
+     , maxwritten_clean
+     , buffers_alloc
+     , stats_reset
+  FROM pg_stat_bgwriter /*postgres_exporter*/`
+	statCheckpointer17 = `
+SELECT num_timed
+     , num_requested
+     , NULL::bigint AS num_done
+     , restartpoints_timed
+     , restartpoints_req
+     , restartpoints_done
+     , write_time
+     , sync_time
+     , buffers_written
+     , NULL::bigint AS slru_written
+     , stats_reset
+  FROM pg_stat_checkpointer /*postgres_exporter*/`
+	statCheckpointer18 = `
+SELECT num_timed
+     , num_requested
+     , num_done
+     , restartpoints_timed
+     , restartpoints_req
+     , restartpoints_done
+     , write_time
+     , sync_time
+     , buffers_written
+     , slru_written
+     , stats_reset
+  FROM pg_stat_checkpointer /*postgres_exporter*/`
+
+	statCheckpointerVersion   = 170000
+	statCheckpointer18Version = 180000
 )
 
 type statBgwriterScraper struct {
@@ -37,9 +76,19 @@ type statBgwriterScraper struct {
 	buffersBackendFsync *prometheus.Desc
 	buffersAlloc        *prometheus.Desc
 	statsReset          *prometheus.Desc
+
+	// pg_stat_checkpointer, PostgreSQL 17+
+	checkpointsDone        *prometheus.Desc
+	restartpointsTimed     *prometheus.Desc
+	restartpointsReq       *prometheus.Desc
+	restartpointsDone      *prometheus.Desc
+	slruWritten            *prometheus.Desc
+	checkpointerStatsReset *prometheus.Desc
 }
 
-// NewStatBgwriterScraper returns a new Scraper exposing PostgreSQL `pg_stat_bgwriter` view
+// NewStatBgwriterScraper returns a new Scraper exposing PostgreSQL
+// `pg_stat_bgwriter` view, and `pg_stat_checkpointer` on PostgreSQL 17+. The
+// checkpoint metrics keep their pg_stat_bgwriter names on every version.
 func NewStatBgwriterScraper() Scraper {
 	return &statBgwriterScraper{
 		checkpointsTimed: prometheus.NewDesc(
@@ -108,6 +157,42 @@ func NewStatBgwriterScraper() Scraper {
 			nil,
 			nil,
 		),
+		checkpointsDone: prometheus.NewDesc(
+			"postgres_stat_checkpointer_checkpoints_done_total",
+			"Number of checkpoints that have been completed",
+			nil,
+			nil,
+		),
+		restartpointsTimed: prometheus.NewDesc(
+			"postgres_stat_checkpointer_restartpoints_timed_total",
+			"Number of scheduled restartpoints due to timeout or after a failed attempt to perform it",
+			nil,
+			nil,
+		),
+		restartpointsReq: prometheus.NewDesc(
+			"postgres_stat_checkpointer_restartpoints_req_total",
+			"Number of requested restartpoints",
+			nil,
+			nil,
+		),
+		restartpointsDone: prometheus.NewDesc(
+			"postgres_stat_checkpointer_restartpoints_done_total",
+			"Number of restartpoints that have been performed",
+			nil,
+			nil,
+		),
+		slruWritten: prometheus.NewDesc(
+			"postgres_stat_checkpointer_slru_written_total",
+			"Number of SLRU buffers written during checkpoints and restartpoints",
+			nil,
+			nil,
+		),
+		checkpointerStatsReset: prometheus.NewDesc(
+			"postgres_stat_checkpointer_stats_reset_timestamp",
+			"Time at which the checkpointer statistics were last reset",
+			nil,
+			nil,
+		),
 	}
 }
 
@@ -115,7 +200,34 @@ func (*statBgwriterScraper) Name() string {
 	return "StatBgwriterScraper"
 }
 
-func (c *statBgwriterScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
+func (c *statBgwriterScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.checkpointsTimed
+	ch <- c.checkpointsReq
+	ch <- c.checkpointWriteTime
+	ch <- c.checkpointSyncTime
+	ch <- c.buffersCheckpoint
+	ch <- c.buffersClean
+	ch <- c.maxWrittenClean
+	ch <- c.buffersBackend
+	ch <- c.buffersBackendFsync
+	ch <- c.buffersAlloc
+	ch <- c.statsReset
+	ch <- c.checkpointsDone
+	ch <- c.restartpointsTimed
+	ch <- c.restartpointsReq
+	ch <- c.restartpointsDone
+	ch <- c.slruWritten
+	ch <- c.checkpointerStatsReset
+}
+
+func (c *statBgwriterScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if version.Gte(statCheckpointerVersion) {
+		if err := c.scrapeBgwriter17(ctx, conn, ch); err != nil {
+			return err
+		}
+		return c.scrapeCheckpointer(ctx, conn, version, ch)
+	}
+
 	var checkpointsTimedCounter, checkpointsReqCounter,
 		buffersCheckpoint, buffersClean, maxWrittenClean,
 		buffersBackend, buffersBackendFsync, buffersAlloc int64
@@ -151,3 +263,75 @@ func (c *statBgwriterScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Vers
 	ch <- prometheus.MustNewConstMetric(c.statsReset, prometheus.GaugeValue, float64(statsReset.UTC().Unix()))
 	return nil
 }
+
+// scrapeBgwriter17 scrapes what is left of pg_stat_bgwriter on PostgreSQL 17+.
+func (c *statBgwriterScraper) scrapeBgwriter17(ctx context.Context, conn *pgx.Conn, ch chan<- prometheus.Metric) error {
+	var buffersClean, maxWrittenClean, buffersAlloc int64
+	var statsReset time.Time
+
+	if err := conn.QueryRow(ctx, statBgwriter17).
+		Scan(&buffersClean,
+			&maxWrittenClean,
+			&buffersAlloc,
+			&statsReset,
+		); err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.buffersClean, prometheus.CounterValue, float64(buffersClean))
+	ch <- prometheus.MustNewConstMetric(c.maxWrittenClean, prometheus.CounterValue, float64(maxWrittenClean))
+	ch <- prometheus.MustNewConstMetric(c.buffersAlloc, prometheus.CounterValue, float64(buffersAlloc))
+	ch <- prometheus.MustNewConstMetric(c.statsReset, prometheus.GaugeValue, float64(statsReset.UTC().Unix()))
+	return nil
+}
+
+// scrapeCheckpointer scrapes pg_stat_checkpointer, PostgreSQL 17+.
+func (c *statBgwriterScraper) scrapeCheckpointer(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	query := statCheckpointer17
+	if version.Gte(statCheckpointer18Version) {
+		query = statCheckpointer18
+	}
+
+	var numTimed, numRequested, restartpointsTimed, restartpointsReq,
+		restartpointsDone, buffersWritten int64
+	// NULL before PostgreSQL 18
+	var numDone, slruWritten *int64
+	var writeTime, syncTime float64
+	var statsReset time.Time
+
+	if err := conn.QueryRow(ctx, query).
+		Scan(&numTimed,
+			&numRequested,
+			&numDone,
+			&restartpointsTimed,
+			&restartpointsReq,
+			&restartpointsDone,
+			&writeTime,
+			&syncTime,
+			&buffersWritten,
+			&slruWritten,
+			&statsReset,
+		); err != nil {
+		return err
+	}
+
+	// same meaning as the pg_stat_bgwriter columns, keep their names
+	ch <- prometheus.MustNewConstMetric(c.checkpointsTimed, prometheus.CounterValue, float64(numTimed))
+	ch <- prometheus.MustNewConstMetric(c.checkpointsReq, prometheus.CounterValue, float64(numRequested))
+	ch <- prometheus.MustNewConstMetric(c.checkpointWriteTime, prometheus.CounterValue, writeTime/1000)
+	ch <- prometheus.MustNewConstMetric(c.checkpointSyncTime, prometheus.CounterValue, syncTime/1000)
+	ch <- prometheus.MustNewConstMetric(c.buffersCheckpoint, prometheus.CounterValue, float64(buffersWritten))
+
+	ch <- prometheus.MustNewConstMetric(c.restartpointsTimed, prometheus.CounterValue, float64(restartpointsTimed))
+	ch <- prometheus.MustNewConstMetric(c.restartpointsReq, prometheus.CounterValue, float64(restartpointsReq))
+	ch <- prometheus.MustNewConstMetric(c.restartpointsDone, prometheus.CounterValue, float64(restartpointsDone))
+	ch <- prometheus.MustNewConstMetric(c.checkpointerStatsReset, prometheus.GaugeValue, float64(statsReset.UTC().Unix()))
+
+	if numDone != nil {
+		ch <- prometheus.MustNewConstMetric(c.checkpointsDone, prometheus.CounterValue, float64(*numDone))
+	}
+	if slruWritten != nil {
+		ch <- prometheus.MustNewConstMetric(c.slruWritten, prometheus.CounterValue, float64(*slruWritten))
+	}
+	return nil
+}
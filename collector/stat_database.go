@@ -154,6 +154,23 @@ func (*statDatabaseScraper) Name() string {
 	return "StatDatabaseScraper"
 }
 
+func (c *statDatabaseScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.numbackends
+	ch <- c.tupReturned
+	ch <- c.tupFetched
+	ch <- c.tupInserted
+	ch <- c.tupUpdated
+	ch <- c.tupDeleted
+	ch <- c.xactCommit
+	ch <- c.xactRollback
+	ch <- c.blksRead
+	ch <- c.blksHit
+	ch <- c.conflicts
+	ch <- c.deadlocks
+	ch <- c.tempFiles
+	ch <- c.tempBytes
+}
+
 func (c *statDatabaseScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
 	rows, err := conn.Query(ctx, statDatabaseQuery)
 	if err != nil {
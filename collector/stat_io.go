@@ -0,0 +1,300 @@
+package collector
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Scrape query for PostgreSQL 16 and 17, sizes are operations times op_bytes
+	statIO16 = `
+SELECT backend_type
+     , object
+     , context
+     , reads
+     , (reads * op_bytes)::float8 AS read_bytes
+     , read_time
+     , writes
+     , (writes * op_bytes)::float8 AS write_bytes
+     , write_time
+     , writebacks
+     , writeback_time
+     , extends
+     , (extends * op_bytes)::float8 AS extend_bytes
+     , extend_time
+     , hits
+     , evictions
+     , reuses
+     , fsyncs
+     , fsync_time
+  FROM pg_stat_io /*postgres_exporter*/`
+
+	// Scrape query for PostgreSQL 18+, which replaced op_bytes by byte columns
+	statIO18 = `
+SELECT backend_type
+     , object
+     , context
+     , reads
+     , read_bytes::float8
+     , read_time
+     , writes
+     , write_bytes::float8
+     , write_time
+     , writebacks
+     , writeback_time
+     , extends
+     , extend_bytes::float8
+     , extend_time
+     , hits
+     , evictions
+     , reuses
+     , fsyncs
+     , fsync_time
+  FROM pg_stat_io /*postgres_exporter*/`
+
+	statIOVersion   = 160000
+	statIO18Version = 180000
+)
+
+type statIOScraper struct {
+	reads         *prometheus.Desc
+	readBytes     *prometheus.Desc
+	readTime      *prometheus.Desc
+	writes        *prometheus.Desc
+	writeBytes    *prometheus.Desc
+	writeTime     *prometheus.Desc
+	writebacks    *prometheus.Desc
+	writebackTime *prometheus.Desc
+	extends       *prometheus.Desc
+	extendBytes   *prometheus.Desc
+	extendTime    *prometheus.Desc
+	hits          *prometheus.Desc
+	evictions     *prometheus.Desc
+	reuses        *prometheus.Desc
+	fsyncs        *prometheus.Desc
+	fsyncTime     *prometheus.Desc
+}
+
+// NewStatIOScraper returns a new Scraper exposing PostgreSQL `pg_stat_io` view,
+// available on PostgreSQL 16+
+func NewStatIOScraper() Scraper {
+	labels := []string{"backend_type", "object", "context"}
+	return &statIOScraper{
+		reads: prometheus.NewDesc(
+			"postgres_stat_io_reads_total",
+			"Number of read operations",
+			labels,
+			nil,
+		),
+		readBytes: prometheus.NewDesc(
+			"postgres_stat_io_read_bytes_total",
+			"Number of bytes read by read operations",
+			labels,
+			nil,
+		),
+		readTime: prometheus.NewDesc(
+			"postgres_stat_io_read_time_seconds_total",
+			"Time spent in read operations, requires track_io_timing",
+			labels,
+			nil,
+		),
+		writes: prometheus.NewDesc(
+			"postgres_stat_io_writes_total",
+			"Number of write operations",
+			labels,
+			nil,
+		),
+		writeBytes: prometheus.NewDesc(
+			"postgres_stat_io_write_bytes_total",
+			"Number of bytes written by write operations",
+			labels,
+			nil,
+		),
+		writeTime: prometheus.NewDesc(
+			"postgres_stat_io_write_time_seconds_total",
+			"Time spent in write operations, requires track_io_timing",
+			labels,
+			nil,
+		),
+		writebacks: prometheus.NewDesc(
+			"postgres_stat_io_writebacks_total",
+			"Number of requests to the kernel to write out data to permanent storage",
+			labels,
+			nil,
+		),
+		writebackTime: prometheus.NewDesc(
+			"postgres_stat_io_writeback_time_seconds_total",
+			"Time spent in writeback operations, requires track_io_timing",
+			labels,
+			nil,
+		),
+		extends: prometheus.NewDesc(
+			"postgres_stat_io_extends_total",
+			"Number of relation extend operations",
+			labels,
+			nil,
+		),
+		extendBytes: prometheus.NewDesc(
+			"postgres_stat_io_extend_bytes_total",
+			"Number of bytes added by extend operations",
+			labels,
+			nil,
+		),
+		extendTime: prometheus.NewDesc(
+			"postgres_stat_io_extend_time_seconds_total",
+			"Time spent in extend operations, requires track_io_timing",
+			labels,
+			nil,
+		),
+		hits: prometheus.NewDesc(
+			"postgres_stat_io_hits_total",
+			"Number of times a desired block was found in a shared buffer",
+			labels,
+			nil,
+		),
+		evictions: prometheus.NewDesc(
+			"postgres_stat_io_evictions_total",
+			"Number of times a block has been written out from a shared or local buffer in order to make it available for another use",
+			labels,
+			nil,
+		),
+		reuses: prometheus.NewDesc(
+			"postgres_stat_io_reuses_total",
+			"Number of times an existing buffer in a size-limited ring buffer outside of shared buffers was reused",
+			labels,
+			nil,
+		),
+		fsyncs: prometheus.NewDesc(
+			"postgres_stat_io_fsyncs_total",
+			"Number of fsync calls",
+			labels,
+			nil,
+		),
+		fsyncTime: prometheus.NewDesc(
+			"postgres_stat_io_fsync_time_seconds_total",
+			"Time spent in fsync operations, requires track_io_timing",
+			labels,
+			nil,
+		),
+	}
+}
+
+func (*statIOScraper) Name() string {
+	return "StatIOScraper"
+}
+
+func (c *statIOScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.reads
+	ch <- c.readBytes
+	ch <- c.readTime
+	ch <- c.writes
+	ch <- c.writeBytes
+	ch <- c.writeTime
+	ch <- c.writebacks
+	ch <- c.writebackTime
+	ch <- c.extends
+	ch <- c.extendBytes
+	ch <- c.extendTime
+	ch <- c.hits
+	ch <- c.evictions
+	ch <- c.reuses
+	ch <- c.fsyncs
+	ch <- c.fsyncTime
+}
+
+func (c *statIOScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(statIOVersion) {
+		return nil
+	}
+
+	query := statIO16
+	if version.Gte(statIO18Version) {
+		query = statIO18
+	}
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var backendType, object, ioContext string
+	// columns are NULL when the operation does not apply to the
+	// backend_type, object and context
+	var reads, writes, writebacks, extends, hits, evictions, reuses, fsyncs *int64
+	var readBytes, readTime, writeBytes, writeTime, writebackTime,
+		extendBytes, extendTime, fsyncTime *float64
+
+	for rows.Next() {
+		if err := rows.Scan(&backendType,
+			&object,
+			&ioContext,
+			&reads,
+			&readBytes,
+			&readTime,
+			&writes,
+			&writeBytes,
+			&writeTime,
+			&writebacks,
+			&writebackTime,
+			&extends,
+			&extendBytes,
+			&extendTime,
+			&hits,
+			&evictions,
+			&reuses,
+			&fsyncs,
+			&fsyncTime,
+		); err != nil {
+			return err
+		}
+
+		labels := []string{backendType, object, ioContext}
+
+		emitCount(ch, c.reads, reads, labels)
+		emitFloat(ch, c.readBytes, readBytes, 1, labels)
+		emitFloat(ch, c.readTime, readTime, 1000, labels)
+		emitCount(ch, c.writes, writes, labels)
+		emitFloat(ch, c.writeBytes, writeBytes, 1, labels)
+		emitFloat(ch, c.writeTime, writeTime, 1000, labels)
+		emitCount(ch, c.writebacks, writebacks, labels)
+		emitFloat(ch, c.writebackTime, writebackTime, 1000, labels)
+		emitCount(ch, c.extends, extends, labels)
+		emitFloat(ch, c.extendBytes, extendBytes, 1, labels)
+		emitFloat(ch, c.extendTime, extendTime, 1000, labels)
+		emitCount(ch, c.hits, hits, labels)
+		emitCount(ch, c.evictions, evictions, labels)
+		emitCount(ch, c.reuses, reuses, labels)
+		emitCount(ch, c.fsyncs, fsyncs, labels)
+		emitFloat(ch, c.fsyncTime, fsyncTime, 1000, labels)
+	}
+
+	return rows.Err()
+}
+
+// emitCount sends a counter for value, unless it is NULL.
+func emitCount(ch chan<- prometheus.Metric, desc *prometheus.Desc, value *int64, labels []string) {
+	if value == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(*value), labels...)
+}
+
+// emitFloat sends a counter for value divided by divisor, e.g. 1000 to turn
+// milliseconds into seconds, unless it is NULL.
+func emitFloat(ch chan<- prometheus.Metric, desc *prometheus.Desc, value *float64, divisor float64, labels []string) {
+	if value == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, *value/divisor, labels...)
+}
+
+// emitGauge sends a gauge for value, unless it is NULL.
+func emitGauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value *float64, labels []string) {
+	if value == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, *value, labels...)
+}
@@ -143,6 +143,23 @@ func (*statVacuumProgressScraper) Name() string {
 	return "StatVacuumProgressScraper"
 }
 
+func (c *statVacuumProgressScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.running
+	ch <- c.phaseInitializing
+	ch <- c.phaseScanningHeap
+	ch <- c.phaseVacuumingIndexes
+	ch <- c.phaseVacuumingHeap
+	ch <- c.phaseCleaningUpIndexes
+	ch <- c.phaseTruncatingHeap
+	ch <- c.phasePerformingFinalCleanup
+	ch <- c.heapBlksTotal
+	ch <- c.heapBlksScanned
+	ch <- c.heapBlksVacuumed
+	ch <- c.indexVacuumCount
+	ch <- c.maxDeadTuples
+	ch <- c.numDeadTuples
+}
+
 // emitPhaseMetric emits a Prometheus metric for the given vacuum progress phase.
 // It maps PostgreSQL vacuum phases to corresponding phase-specific metrics.
 func (c *statVacuumProgressScraper) emitPhaseMetric(phase, pid, queryStart, schemaname, datname, relname string, ch chan<- prometheus.Metric) {
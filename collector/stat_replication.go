@@ -8,56 +8,153 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-const pgVersion float64 = 10
+const (
+	pgVersion                       = 100000
+	statReplicationReplyTimeVersion = 120000
+)
 
 // When pg_basebackup is running in stream mode, it opens a second connection
 // to the server and starts streaming the transaction log in parallel while
-// running the backup. In both connections (state=backup and state=streaming) the
-// pg_log_location_diff is null and it requires to be excluded
+// running the backup. In both connections (state=backup and state=streaming)
+// some LSNs are null, the byte lags of those walsenders are not exposed but
+// the walsenders still are, by postgres_stat_replication_info. The lag
+// intervals are null when the standby is idle or has not replied yet, and are
+// then not exposed. They do not exist before 10. Without the privileges of
+// pg_read_all_stats, every column but application_name is null.
 const (
 	// Scrape query
-	statReplicationLagBytes9x = `
-WITH pg_replication AS (
-  SELECT application_name
-       , client_addr
-       , state
-       , sync_state
-       , ( CASE when pg_is_in_recovery()
-           THEN pg_xlog_location_diff(pg_last_xlog_receive_location(), replay_location)::float
-           ELSE pg_xlog_location_diff(pg_current_xlog_location(), replay_location)::float
-		   END
-	     ) AS pg_xlog_location_diff
-    FROM pg_stat_replication
+	statReplication9x = `
+WITH current AS (
+  SELECT CASE WHEN pg_is_in_recovery()
+              THEN pg_last_xlog_receive_location()
+              ELSE pg_current_xlog_location()
+         END AS location
 )
-SELECT * FROM pg_replication WHERE pg_xlog_location_diff IS NOT NULL /*postgres_exporter*/`
-
-	statReplicationLagBytes = `
-WITH pg_replication AS (
-  SELECT application_name
-       , client_addr
-       , state
-       , sync_state
-       , ( CASE when pg_is_in_recovery()
-           THEN pg_wal_lsn_diff(pg_last_wal_receive_lsn(), replay_lsn)::float
-           ELSE pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn)::float
-		   END
-	     ) AS pg_xlog_location_diff
-    FROM pg_stat_replication
+SELECT COALESCE(application_name, '')
+     , client_addr
+     , COALESCE(state, '')
+     , COALESCE(sync_state, '')
+     , pg_xlog_location_diff(current.location, sent_location)::float8
+     , pg_xlog_location_diff(current.location, write_location)::float8
+     , pg_xlog_location_diff(current.location, flush_location)::float8
+     , pg_xlog_location_diff(current.location, replay_location)::float8
+     , NULL::float8 AS write_lag
+     , NULL::float8 AS flush_lag
+     , NULL::float8 AS replay_lag
+     , NULL::float8 AS reply_age
+  FROM pg_stat_replication, current /*postgres_exporter*/`
+
+	statReplication10 = `
+WITH current AS (
+  SELECT CASE WHEN pg_is_in_recovery()
+              THEN pg_last_wal_receive_lsn()
+              ELSE pg_current_wal_lsn()
+         END AS lsn
 )
-SELECT * FROM pg_replication WHERE pg_xlog_location_diff IS NOT NULL /*postgres_exporter*/`
+SELECT COALESCE(application_name, '')
+     , client_addr
+     , COALESCE(state, '')
+     , COALESCE(sync_state, '')
+     , pg_wal_lsn_diff(current.lsn, sent_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, write_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, flush_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, replay_lsn)::float8
+     , EXTRACT(EPOCH FROM write_lag)::float8
+     , EXTRACT(EPOCH FROM flush_lag)::float8
+     , EXTRACT(EPOCH FROM replay_lag)::float8
+     , NULL::float8 AS reply_age
+  FROM pg_stat_replication, current /*postgres_exporter*/`
+
+	statReplication12 = `
+WITH current AS (
+  SELECT CASE WHEN pg_is_in_recovery()
+              THEN pg_last_wal_receive_lsn()
+              ELSE pg_current_wal_lsn()
+         END AS lsn
+)
+SELECT COALESCE(application_name, '')
+     , client_addr
+     , COALESCE(state, '')
+     , COALESCE(sync_state, '')
+     , pg_wal_lsn_diff(current.lsn, sent_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, write_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, flush_lsn)::float8
+     , pg_wal_lsn_diff(current.lsn, replay_lsn)::float8
+     , EXTRACT(EPOCH FROM write_lag)::float8
+     , EXTRACT(EPOCH FROM flush_lag)::float8
+     , EXTRACT(EPOCH FROM replay_lag)::float8
+     , EXTRACT(EPOCH FROM now() - reply_time)::float8
+  FROM pg_stat_replication, current /*postgres_exporter*/`
 )
 
 type statReplicationScraper struct {
-	lagBytes *prometheus.Desc
+	info          *prometheus.Desc
+	lagBytes      *prometheus.Desc
+	sentLagBytes  *prometheus.Desc
+	writeLagBytes *prometheus.Desc
+	flushLagBytes *prometheus.Desc
+	writeLag      *prometheus.Desc
+	flushLag      *prometheus.Desc
+	replayLag     *prometheus.Desc
+	replyAge      *prometheus.Desc
 }
 
 // NewStatReplicationScraper returns a new Scraper exposing postgres pg_stat_replication
 func NewStatReplicationScraper() Scraper {
+	labels := []string{"application_name", "client_addr", "state", "sync_state"}
 	return &statReplicationScraper{
+		info: prometheus.NewDesc(
+			"postgres_stat_replication_info",
+			"Walsender connected to a standby or a base backup, exposed even when its LSNs and lags are unknown",
+			labels,
+			nil,
+		),
 		lagBytes: prometheus.NewDesc(
 			"postgres_stat_replication_lag_bytes",
 			"delay in bytes pg_wal_lsn_diff(pg_current_wal_lsn(), replay_location)",
-			[]string{"application_name", "client_addr", "state", "sync_state"},
+			labels,
+			nil,
+		),
+		sentLagBytes: prometheus.NewDesc(
+			"postgres_stat_replication_sent_lag_bytes",
+			"WAL not sent yet to the standby in bytes, pg_wal_lsn_diff(pg_current_wal_lsn(), sent_lsn)",
+			labels,
+			nil,
+		),
+		writeLagBytes: prometheus.NewDesc(
+			"postgres_stat_replication_write_lag_bytes",
+			"WAL not written to disk by the standby yet in bytes, pg_wal_lsn_diff(pg_current_wal_lsn(), write_lsn)",
+			labels,
+			nil,
+		),
+		flushLagBytes: prometheus.NewDesc(
+			"postgres_stat_replication_flush_lag_bytes",
+			"WAL not flushed to disk by the standby yet in bytes, pg_wal_lsn_diff(pg_current_wal_lsn(), flush_lsn)",
+			labels,
+			nil,
+		),
+		writeLag: prometheus.NewDesc(
+			"postgres_stat_replication_write_lag_seconds",
+			"Time elapsed between flushing recent WAL locally and receiving notification that the standby has written it. Not exposed when unknown, e.g. when the standby is idle",
+			labels,
+			nil,
+		),
+		flushLag: prometheus.NewDesc(
+			"postgres_stat_replication_flush_lag_seconds",
+			"Time elapsed between flushing recent WAL locally and receiving notification that the standby has written and flushed it. Not exposed when unknown, e.g. when the standby is idle",
+			labels,
+			nil,
+		),
+		replayLag: prometheus.NewDesc(
+			"postgres_stat_replication_replay_lag_seconds",
+			"Time elapsed between flushing recent WAL locally and receiving notification that the standby has written, flushed and applied it. Not exposed when unknown, e.g. when the standby is idle",
+			labels,
+			nil,
+		),
+		replyAge: prometheus.NewDesc(
+			"postgres_stat_replication_reply_age_seconds",
+			"Time elapsed since the last reply message received from the standby",
+			labels,
 			nil,
 		),
 	}
@@ -67,16 +164,28 @@ func (*statReplicationScraper) Name() string {
 	return "StatReplicationScraper"
 }
 
-func (c *statReplicationScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
-	var rows pgx.Rows
-	var err error
+func (c *statReplicationScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	ch <- c.lagBytes
+	ch <- c.sentLagBytes
+	ch <- c.writeLagBytes
+	ch <- c.flushLagBytes
+	ch <- c.writeLag
+	ch <- c.flushLag
+	ch <- c.replayLag
+	ch <- c.replyAge
+}
 
-	if version.Gte(pgVersion) {
-		rows, err = conn.Query(ctx, statReplicationLagBytes)
-	} else {
-		rows, err = conn.Query(ctx, statReplicationLagBytes9x)
+func (c *statReplicationScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	query := statReplication9x
+	switch {
+	case version.Gte(statReplicationReplyTimeVersion):
+		query = statReplication12
+	case version.Gte(pgVersion):
+		query = statReplication10
 	}
 
+	rows, err := conn.Query(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -84,25 +193,46 @@ func (c *statReplicationScraper) Scrape(ctx context.Context, conn *pgx.Conn, ver
 
 	var applicationName, state, syncState string
 	var clientAddr net.IP
-	var pgXlogLocationDiff float64
+	// NULL when the walsender has not reached that LSN yet, the lag
+	// intervals before PostgreSQL 10 and reply_age before PostgreSQL 12
+	var sentLagBytes, writeLagBytes, flushLagBytes, replayLagBytes,
+		writeLag, flushLag, replayLag, replyAge *float64
+	// without pg_read_all_stats, walsenders of the same application share
+	// the same labels
+	seen := make(map[[4]string]bool)
 
 	for rows.Next() {
 		if err := rows.Scan(&applicationName,
 			&clientAddr,
 			&state,
 			&syncState,
-			&pgXlogLocationDiff); err != nil {
+			&sentLagBytes,
+			&writeLagBytes,
+			&flushLagBytes,
+			&replayLagBytes,
+			&writeLag,
+			&flushLag,
+			&replayLag,
+			&replyAge); err != nil {
 			return err
 		}
 
+		labels := []string{applicationName, clientAddr.String(), state, syncState}
+
+		if key := [4]string(labels); !seen[key] {
+			seen[key] = true
+			ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, infoMetricValue, labels...)
+		}
+
 		// postgres_stat_replication_lag_bytes
-		ch <- prometheus.MustNewConstMetric(c.lagBytes,
-			prometheus.GaugeValue,
-			pgXlogLocationDiff,
-			applicationName,
-			clientAddr.String(),
-			state,
-			syncState)
+		emitGauge(ch, c.lagBytes, replayLagBytes, labels)
+		emitGauge(ch, c.sentLagBytes, sentLagBytes, labels)
+		emitGauge(ch, c.writeLagBytes, writeLagBytes, labels)
+		emitGauge(ch, c.flushLagBytes, flushLagBytes, labels)
+		emitGauge(ch, c.writeLag, writeLag, labels)
+		emitGauge(ch, c.flushLag, flushLag, labels)
+		emitGauge(ch, c.replayLag, replayLag, labels)
+		emitGauge(ch, c.replyAge, replyAge, labels)
 	}
 
 	err = rows.Err()
@@ -0,0 +1,346 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// statStatementsSchemaQuery returns the schema and the version the
+	// extension is installed in
+	statStatementsSchemaQuery = `
+SELECT n.nspname
+     , e.extversion
+  FROM pg_extension e
+  JOIN pg_namespace n ON n.oid = e.extnamespace
+ WHERE e.extname = 'pg_stat_statements' /*postgres_exporter*/`
+
+	// statStatementsQuery keeps the top $1 statements by execution time and
+	// folds the rest into an "other" series per database and user, so the
+	// series add up to the whole workload. Statements are summed by queryid,
+	// database and user, since PostgreSQL 14+ tracks top level and nested
+	// executions separately. The placeholders are filled with the column
+	// names of the extension version.
+	statStatementsQuery = `
+WITH statements AS (
+  SELECT s.queryid::text AS queryid
+       , d.datname
+       , r.rolname AS usename
+       , sum(s.calls) AS calls
+       , sum(s.%[2]s) AS exec_time
+       , sum(s.rows) AS rows
+       , sum(s.shared_blks_hit) AS shared_blks_hit
+       , sum(s.shared_blks_read) AS shared_blks_read
+       , sum(s.shared_blks_dirtied) AS shared_blks_dirtied
+       , sum(s.shared_blks_written) AS shared_blks_written
+       , sum(s.local_blks_hit) AS local_blks_hit
+       , sum(s.local_blks_read) AS local_blks_read
+       , sum(s.local_blks_dirtied) AS local_blks_dirtied
+       , sum(s.local_blks_written) AS local_blks_written
+       , sum(s.temp_blks_read) AS temp_blks_read
+       , sum(s.temp_blks_written) AS temp_blks_written
+       , sum(s.%[3]s) AS blk_read_time
+       , sum(s.%[4]s) AS blk_write_time
+       , sum(%[5]s) AS wal_bytes
+    FROM %[1]s s
+    JOIN pg_database d ON d.oid = s.dbid
+    JOIN pg_roles r ON r.oid = s.userid
+   WHERE s.queryid IS NOT NULL
+   GROUP BY s.queryid, d.datname, r.rolname
+), ranked AS (
+  SELECT *, row_number() OVER (ORDER BY exec_time DESC) <= $1 AS top
+    FROM statements
+)
+SELECT CASE WHEN top THEN queryid ELSE 'other' END
+     , datname
+     , usename
+     , sum(calls)::float8
+     , sum(exec_time)::float8
+     , sum(rows)::float8
+     , sum(shared_blks_hit)::float8
+     , sum(shared_blks_read)::float8
+     , sum(shared_blks_dirtied)::float8
+     , sum(shared_blks_written)::float8
+     , sum(local_blks_hit)::float8
+     , sum(local_blks_read)::float8
+     , sum(local_blks_dirtied)::float8
+     , sum(local_blks_written)::float8
+     , sum(temp_blks_read)::float8
+     , sum(temp_blks_written)::float8
+     , sum(blk_read_time)::float8
+     , sum(blk_write_time)::float8
+     , sum(wal_bytes)::float8
+  FROM ranked
+ GROUP BY 1, 2, 3 /*postgres_exporter*/`
+
+	// DefaultStatStatementsLimit is the default number of statements exposed
+	// by the stat_statements collector
+	DefaultStatStatementsLimit = 100
+)
+
+type statStatementsScraper struct {
+	limit int
+
+	calls             *prometheus.Desc
+	execTime          *prometheus.Desc
+	meanExecTime      *prometheus.Desc
+	rows              *prometheus.Desc
+	sharedBlksHit     *prometheus.Desc
+	sharedBlksRead    *prometheus.Desc
+	sharedBlksDirtied *prometheus.Desc
+	sharedBlksWritten *prometheus.Desc
+	localBlksHit      *prometheus.Desc
+	localBlksRead     *prometheus.Desc
+	localBlksDirtied  *prometheus.Desc
+	localBlksWritten  *prometheus.Desc
+	tempBlksRead      *prometheus.Desc
+	tempBlksWritten   *prometheus.Desc
+	blkReadTime       *prometheus.Desc
+	blkWriteTime      *prometheus.Desc
+	walBytes          *prometheus.Desc
+}
+
+// NewStatStatementsScraper returns a new Scraper exposing the top limit
+// statements of the `pg_stat_statements` extension by execution time, the
+// others being folded into a series with queryid="other" per database and
+// user
+func NewStatStatementsScraper(limit int) Scraper {
+	labels := []string{"queryid", "datname", "usename"}
+	return &statStatementsScraper{
+		limit: limit,
+		calls: prometheus.NewDesc(
+			"postgres_stat_statements_calls_total",
+			"Number of times the statement was executed",
+			labels,
+			nil,
+		),
+		execTime: prometheus.NewDesc(
+			"postgres_stat_statements_exec_time_seconds_total",
+			"Total time spent executing the statement",
+			labels,
+			nil,
+		),
+		meanExecTime: prometheus.NewDesc(
+			"postgres_stat_statements_mean_exec_time_seconds",
+			"Mean time spent executing the statement",
+			labels,
+			nil,
+		),
+		rows: prometheus.NewDesc(
+			"postgres_stat_statements_rows_total",
+			"Total number of rows retrieved or affected by the statement",
+			labels,
+			nil,
+		),
+		sharedBlksHit: prometheus.NewDesc(
+			"postgres_stat_statements_shared_blks_hit_total",
+			"Total number of shared block cache hits by the statement",
+			labels,
+			nil,
+		),
+		sharedBlksRead: prometheus.NewDesc(
+			"postgres_stat_statements_shared_blks_read_total",
+			"Total number of shared blocks read by the statement",
+			labels,
+			nil,
+		),
+		sharedBlksDirtied: prometheus.NewDesc(
+			"postgres_stat_statements_shared_blks_dirtied_total",
+			"Total number of shared blocks dirtied by the statement",
+			labels,
+			nil,
+		),
+		sharedBlksWritten: prometheus.NewDesc(
+			"postgres_stat_statements_shared_blks_written_total",
+			"Total number of shared blocks written by the statement",
+			labels,
+			nil,
+		),
+		localBlksHit: prometheus.NewDesc(
+			"postgres_stat_statements_local_blks_hit_total",
+			"Total number of local block cache hits by the statement",
+			labels,
+			nil,
+		),
+		localBlksRead: prometheus.NewDesc(
+			"postgres_stat_statements_local_blks_read_total",
+			"Total number of local blocks read by the statement",
+			labels,
+			nil,
+		),
+		localBlksDirtied: prometheus.NewDesc(
+			"postgres_stat_statements_local_blks_dirtied_total",
+			"Total number of local blocks dirtied by the statement",
+			labels,
+			nil,
+		),
+		localBlksWritten: prometheus.NewDesc(
+			"postgres_stat_statements_local_blks_written_total",
+			"Total number of local blocks written by the statement",
+			labels,
+			nil,
+		),
+		tempBlksRead: prometheus.NewDesc(
+			"postgres_stat_statements_temp_blks_read_total",
+			"Total number of temp blocks read by the statement",
+			labels,
+			nil,
+		),
+		tempBlksWritten: prometheus.NewDesc(
+			"postgres_stat_statements_temp_blks_written_total",
+			"Total number of temp blocks written by the statement",
+			labels,
+			nil,
+		),
+		blkReadTime: prometheus.NewDesc(
+			"postgres_stat_statements_blk_read_time_seconds_total",
+			"Total time the statement spent reading shared blocks, requires track_io_timing",
+			labels,
+			nil,
+		),
+		blkWriteTime: prometheus.NewDesc(
+			"postgres_stat_statements_blk_write_time_seconds_total",
+			"Total time the statement spent writing shared blocks, requires track_io_timing",
+			labels,
+			nil,
+		),
+		walBytes: prometheus.NewDesc(
+			"postgres_stat_statements_wal_bytes_total",
+			"Total amount of WAL generated by the statement in bytes",
+			labels,
+			nil,
+		),
+	}
+}
+
+func (*statStatementsScraper) Name() string {
+	return "StatStatementsScraper"
+}
+
+func (c *statStatementsScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.calls
+	ch <- c.execTime
+	ch <- c.meanExecTime
+	ch <- c.rows
+	ch <- c.sharedBlksHit
+	ch <- c.sharedBlksRead
+	ch <- c.sharedBlksDirtied
+	ch <- c.sharedBlksWritten
+	ch <- c.localBlksHit
+	ch <- c.localBlksRead
+	ch <- c.localBlksDirtied
+	ch <- c.localBlksWritten
+	ch <- c.tempBlksRead
+	ch <- c.tempBlksWritten
+	ch <- c.blkReadTime
+	ch <- c.blkWriteTime
+	ch <- c.walBytes
+}
+
+func (c *statStatementsScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
+	var schema, extversion string
+	if err := conn.QueryRow(ctx, statStatementsSchemaQuery).Scan(&schema, &extversion); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// extension not installed, nothing to expose
+			return nil
+		}
+		return err
+	}
+
+	// the columns depend on the version of the extension rather than of the
+	// server, it is only updated by ALTER EXTENSION after a major upgrade
+	execTime, blkReadTime, blkWriteTime, walBytes := "total_time", "blk_read_time", "blk_write_time", "NULL::numeric"
+	if extensionVersionGte(extversion, 1, 8) {
+		// 1.8, PostgreSQL 13, renamed total_time and added wal_bytes
+		execTime, walBytes = "total_exec_time", "s.wal_bytes"
+	}
+	if extensionVersionGte(extversion, 1, 11) {
+		// 1.11, PostgreSQL 17, renamed blk_read_time and blk_write_time
+		blkReadTime, blkWriteTime = "shared_blk_read_time", "shared_blk_write_time"
+	}
+
+	view := pgx.Identifier{schema, "pg_stat_statements"}.Sanitize()
+	query := fmt.Sprintf(statStatementsQuery, view, execTime, blkReadTime, blkWriteTime, walBytes)
+
+	rows, err := conn.Query(ctx, query, c.limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var queryID, datname, usename string
+	var calls, execTimeMs, rowsCount, sharedBlksHit, sharedBlksRead, sharedBlksDirtied,
+		sharedBlksWritten, localBlksHit, localBlksRead, localBlksDirtied, localBlksWritten,
+		tempBlksRead, tempBlksWritten, blkReadTimeMs, blkWriteTimeMs float64
+	// NULL before pg_stat_statements 1.8
+	var walBytesCount *float64
+
+	for rows.Next() {
+		if err := rows.Scan(&queryID,
+			&datname,
+			&usename,
+			&calls,
+			&execTimeMs,
+			&rowsCount,
+			&sharedBlksHit,
+			&sharedBlksRead,
+			&sharedBlksDirtied,
+			&sharedBlksWritten,
+			&localBlksHit,
+			&localBlksRead,
+			&localBlksDirtied,
+			&localBlksWritten,
+			&tempBlksRead,
+			&tempBlksWritten,
+			&blkReadTimeMs,
+			&blkWriteTimeMs,
+			&walBytesCount,
+		); err != nil {
+			return err
+		}
+
+		labels := []string{queryID, datname, usename}
+
+		ch <- prometheus.MustNewConstMetric(c.calls, prometheus.CounterValue, calls, labels...)
+		ch <- prometheus.MustNewConstMetric(c.execTime, prometheus.CounterValue, execTimeMs/1000, labels...)
+		if calls > 0 {
+			ch <- prometheus.MustNewConstMetric(c.meanExecTime, prometheus.GaugeValue, execTimeMs/1000/calls, labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(c.rows, prometheus.CounterValue, rowsCount, labels...)
+		ch <- prometheus.MustNewConstMetric(c.sharedBlksHit, prometheus.CounterValue, sharedBlksHit, labels...)
+		ch <- prometheus.MustNewConstMetric(c.sharedBlksRead, prometheus.CounterValue, sharedBlksRead, labels...)
+		ch <- prometheus.MustNewConstMetric(c.sharedBlksDirtied, prometheus.CounterValue, sharedBlksDirtied, labels...)
+		ch <- prometheus.MustNewConstMetric(c.sharedBlksWritten, prometheus.CounterValue, sharedBlksWritten, labels...)
+		ch <- prometheus.MustNewConstMetric(c.localBlksHit, prometheus.CounterValue, localBlksHit, labels...)
+		ch <- prometheus.MustNewConstMetric(c.localBlksRead, prometheus.CounterValue, localBlksRead, labels...)
+		ch <- prometheus.MustNewConstMetric(c.localBlksDirtied, prometheus.CounterValue, localBlksDirtied, labels...)
+		ch <- prometheus.MustNewConstMetric(c.localBlksWritten, prometheus.CounterValue, localBlksWritten, labels...)
+		ch <- prometheus.MustNewConstMetric(c.tempBlksRead, prometheus.CounterValue, tempBlksRead, labels...)
+		ch <- prometheus.MustNewConstMetric(c.tempBlksWritten, prometheus.CounterValue, tempBlksWritten, labels...)
+		ch <- prometheus.MustNewConstMetric(c.blkReadTime, prometheus.CounterValue, blkReadTimeMs/1000, labels...)
+		ch <- prometheus.MustNewConstMetric(c.blkWriteTime, prometheus.CounterValue, blkWriteTimeMs/1000, labels...)
+		emitFloat(ch, c.walBytes, walBytesCount, 1, labels)
+	}
+
+	return rows.Err()
+}
+
+// extensionVersionGte returns whether the extension version extversion, e.g.
+// "1.10", is major.minor or newer.
+func extensionVersionGte(extversion string, major, minor int) bool {
+	majorPart, minorPart, _ := strings.Cut(extversion, ".")
+	extMajor, err := strconv.Atoi(majorPart)
+	if err != nil {
+		return false
+	}
+	// a missing or malformed minor version is 0
+	extMinor, _ := strconv.Atoi(minorPart)
+
+	return extMajor > major || (extMajor == major && extMinor >= minor)
+}
@@ -62,6 +62,12 @@ func (*statUserIndexesScraper) Name() string {
 	return "StatUserIndexesScraper"
 }
 
+func (c *statUserIndexesScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.idxScan
+	ch <- c.idxTupRead
+	ch <- c.idxTupFetch
+}
+
 func (c *statUserIndexesScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
 	var datname string
 	if err := conn.QueryRow(ctx, "SELECT current_database() /*postgres_exporter*/").Scan(&datname); err != nil {
@@ -42,28 +42,67 @@ SELECT schemaname
   FROM pg_stat_user_tables
  WHERE schemaname != 'information_schema'
   AND idx_tup_fetch IS NOT NULL /*postgres_exporter*/`
+
+	// Scrape query for the age of each table, the TOAST table is frozen by
+	// the same vacuum so the oldest of both is reported. The freeze max ages
+	// of a table and of its TOAST table can only be lowered by their storage
+	// parameters, the first one reached is reported.
+	statUserTablesWraparoundQuery = `
+WITH settings AS (
+  SELECT current_setting('autovacuum_freeze_max_age')::bigint AS xid
+       , current_setting('autovacuum_multixact_freeze_max_age')::bigint AS mxid
+)
+SELECT n.nspname
+     , c.relname
+     , GREATEST(age(c.relfrozenxid), age(t.relfrozenxid))::float8
+     , GREATEST(mxid_age(c.relminmxid), mxid_age(t.relminmxid))::float8
+     , LEAST(fc.xid - age(c.relfrozenxid), ft.xid - age(t.relfrozenxid))::float8
+     , LEAST(fc.mxid - mxid_age(c.relminmxid), ft.mxid - mxid_age(t.relminmxid))::float8
+  FROM pg_class c
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+  LEFT JOIN pg_class t ON t.oid = c.reltoastrelid
+ CROSS JOIN settings s
+ CROSS JOIN LATERAL (
+   SELECT LEAST(s.xid, min(CASE WHEN o.option_name = 'autovacuum_freeze_max_age' THEN o.option_value::bigint END)) AS xid
+        , LEAST(s.mxid, min(CASE WHEN o.option_name = 'autovacuum_multixact_freeze_max_age' THEN o.option_value::bigint END)) AS mxid
+     FROM pg_options_to_table(c.reloptions) o
+ ) fc
+ CROSS JOIN LATERAL (
+   SELECT LEAST(s.xid, min(CASE WHEN o.option_name = 'autovacuum_freeze_max_age' THEN o.option_value::bigint END)) AS xid
+        , LEAST(s.mxid, min(CASE WHEN o.option_name = 'autovacuum_multixact_freeze_max_age' THEN o.option_value::bigint END)) AS mxid
+     FROM pg_options_to_table(t.reloptions) o
+ ) ft
+ WHERE c.relkind IN ('r', 'm')
+   AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+   AND n.nspname !~ '^pg_toast' /*postgres_exporter*/`
 )
 
 type statUserTablesScraper struct {
-	seqScan          *prometheus.Desc
-	seqTupRead       *prometheus.Desc
-	idxScan          *prometheus.Desc
-	idxTupFetch      *prometheus.Desc
-	nTupIns          *prometheus.Desc
-	nTupUpd          *prometheus.Desc
-	nTupDel          *prometheus.Desc
-	nTupHotUpd       *prometheus.Desc
-	nLiveTup         *prometheus.Desc
-	nDeadTup         *prometheus.Desc
-	nModSinceAnalyze *prometheus.Desc
-	lastAnalyze      *prometheus.Desc
-	lastAutoAnalyze  *prometheus.Desc
-	lastVacuum       *prometheus.Desc
-	lastAutoVacuum   *prometheus.Desc
-	vacuumCount      *prometheus.Desc
-	autovacuumCount  *prometheus.Desc
-	analyzeCount     *prometheus.Desc
-	autoanalyzeCount *prometheus.Desc
+	seqScan                 *prometheus.Desc
+	seqTupRead              *prometheus.Desc
+	idxScan                 *prometheus.Desc
+	idxTupFetch             *prometheus.Desc
+	nTupIns                 *prometheus.Desc
+	nTupUpd                 *prometheus.Desc
+	nTupDel                 *prometheus.Desc
+	nTupHotUpd              *prometheus.Desc
+	nLiveTup                *prometheus.Desc
+	nDeadTup                *prometheus.Desc
+	nModSinceAnalyze        *prometheus.Desc
+	lastAnalyze             *prometheus.Desc
+	lastAutoAnalyze         *prometheus.Desc
+	lastVacuum              *prometheus.Desc
+	lastAutoVacuum          *prometheus.Desc
+	vacuumCount             *prometheus.Desc
+	autovacuumCount         *prometheus.Desc
+	analyzeCount            *prometheus.Desc
+	autoanalyzeCount        *prometheus.Desc
+	xidAge                  *prometheus.Desc
+	mxidAge                 *prometheus.Desc
+	xidFreezeRemaining      *prometheus.Desc
+	mxidFreezeRemaining     *prometheus.Desc
+	xidWraparoundRemaining  *prometheus.Desc
+	mxidWraparoundRemaining *prometheus.Desc
 }
 
 // NewStatUserTablesScraper returns a new Scraper exposing postgres pg_stat_database view
@@ -183,6 +222,42 @@ func NewStatUserTablesScraper() Scraper {
 			[]string{"datname", "schemaname", "relname"},
 			nil,
 		),
+		xidAge: prometheus.NewDesc(
+			"postgres_stat_user_tables_xid_age",
+			"Age of the oldest unfrozen transaction ID of this table or its TOAST table, age(relfrozenxid)",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
+		mxidAge: prometheus.NewDesc(
+			"postgres_stat_user_tables_mxid_age",
+			"Age of the oldest unfrozen MultiXact ID of this table or its TOAST table, mxid_age(relminmxid)",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
+		xidFreezeRemaining: prometheus.NewDesc(
+			"postgres_stat_user_tables_xid_freeze_max_age_remaining",
+			"Transaction IDs left before autovacuum_freeze_max_age, or the lower autovacuum_freeze_max_age storage parameter of this table or its TOAST table, forces an anti-wraparound autovacuum, negative once reached",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
+		mxidFreezeRemaining: prometheus.NewDesc(
+			"postgres_stat_user_tables_mxid_freeze_max_age_remaining",
+			"MultiXact IDs left before autovacuum_multixact_freeze_max_age, or the lower autovacuum_multixact_freeze_max_age storage parameter of this table or its TOAST table, forces an anti-wraparound autovacuum, negative once reached",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
+		xidWraparoundRemaining: prometheus.NewDesc(
+			"postgres_stat_user_tables_xid_wraparound_remaining",
+			"Transaction IDs left before this table or its TOAST table reaches the 2^31 wraparound limit",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
+		mxidWraparoundRemaining: prometheus.NewDesc(
+			"postgres_stat_user_tables_mxid_wraparound_remaining",
+			"MultiXact IDs left before this table or its TOAST table reaches the 2^31 wraparound limit",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
 	}
 }
 
@@ -190,7 +265,35 @@ func (*statUserTablesScraper) Name() string {
 	return "StatUserTablesScraper"
 }
 
-func (c *statUserTablesScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
+func (c *statUserTablesScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.seqScan
+	ch <- c.seqTupRead
+	ch <- c.idxScan
+	ch <- c.idxTupFetch
+	ch <- c.nTupIns
+	ch <- c.nTupUpd
+	ch <- c.nTupDel
+	ch <- c.nTupHotUpd
+	ch <- c.nLiveTup
+	ch <- c.nDeadTup
+	ch <- c.nModSinceAnalyze
+	ch <- c.lastAnalyze
+	ch <- c.lastAutoAnalyze
+	ch <- c.lastVacuum
+	ch <- c.lastAutoVacuum
+	ch <- c.vacuumCount
+	ch <- c.autovacuumCount
+	ch <- c.analyzeCount
+	ch <- c.autoanalyzeCount
+	ch <- c.xidAge
+	ch <- c.mxidAge
+	ch <- c.xidFreezeRemaining
+	ch <- c.mxidFreezeRemaining
+	ch <- c.xidWraparoundRemaining
+	ch <- c.mxidWraparoundRemaining
+}
+
+func (c *statUserTablesScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
 	var datname string
 	if err := conn.QueryRow(ctx, "SELECT current_database() /*postgres_exporter*/").Scan(&datname); err != nil {
 		return err
@@ -282,5 +385,45 @@ func (c *statUserTablesScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Ve
 		return err
 	}
 
+	if version.Gte(mxidAgeVersion) {
+		return c.scrapeWraparound(ctx, conn, datname, ch)
+	}
 	return nil
 }
+
+// scrapeWraparound scrapes the transaction ID and MultiXact ID age of each
+// table, and how far they are from anti-wraparound autovacuums and from the
+// wraparound limit.
+func (c *statUserTablesScraper) scrapeWraparound(ctx context.Context, conn *pgx.Conn, datname string, ch chan<- prometheus.Metric) error {
+	rows, err := conn.Query(ctx, statUserTablesWraparoundQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var schemaname, relname string
+	var xidAge, mxidAge, xidFreezeRemaining, mxidFreezeRemaining float64
+
+	for rows.Next() {
+		if err := rows.Scan(&schemaname,
+			&relname,
+			&xidAge,
+			&mxidAge,
+			&xidFreezeRemaining,
+			&mxidFreezeRemaining,
+		); err != nil {
+			return err
+		}
+
+		labels := []string{datname, schemaname, relname}
+
+		ch <- prometheus.MustNewConstMetric(c.xidAge, prometheus.GaugeValue, xidAge, labels...)
+		ch <- prometheus.MustNewConstMetric(c.mxidAge, prometheus.GaugeValue, mxidAge, labels...)
+		ch <- prometheus.MustNewConstMetric(c.xidFreezeRemaining, prometheus.GaugeValue, xidFreezeRemaining, labels...)
+		ch <- prometheus.MustNewConstMetric(c.mxidFreezeRemaining, prometheus.GaugeValue, mxidFreezeRemaining, labels...)
+		ch <- prometheus.MustNewConstMetric(c.xidWraparoundRemaining, prometheus.GaugeValue, wraparoundLimit-xidAge, labels...)
+		ch <- prometheus.MustNewConstMetric(c.mxidWraparoundRemaining, prometheus.GaugeValue, wraparoundLimit-mxidAge, labels...)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Scrape query for PostgreSQL 14 to 17
+	statWal14 = `
+SELECT wal_records
+     , wal_fpi
+     , wal_bytes::float8
+     , wal_buffers_full
+     , wal_write
+     , wal_sync
+     , wal_write_time
+     , wal_sync_time
+     , stats_reset
+  FROM pg_stat_wal /*postgres_exporter*/`
+
+	// Scrape query for PostgreSQL 18+, the write and sync columns moved to
+	// pg_stat_io (object=wal)
+	statWal18 = `
+SELECT wal_records
+     , wal_fpi
+     , wal_bytes::float8
+     , wal_buffers_full
+     , NULL::bigint AS wal_write
+     , NULL::bigint AS wal_sync
+     , NULL::float8 AS wal_write_time
+     , NULL::float8 AS wal_sync_time
+     , stats_reset
+  FROM pg_stat_wal /*postgres_exporter*/`
+
+	statWalVersion   = 140000
+	statWal18Version = 180000
+)
+
+type statWalScraper struct {
+	records     *prometheus.Desc
+	fpi         *prometheus.Desc
+	bytes       *prometheus.Desc
+	buffersFull *prometheus.Desc
+	write       *prometheus.Desc
+	sync        *prometheus.Desc
+	writeTime   *prometheus.Desc
+	syncTime    *prometheus.Desc
+	statsReset  *prometheus.Desc
+}
+
+// NewStatWalScraper returns a new Scraper exposing PostgreSQL `pg_stat_wal`
+// view, available on PostgreSQL 14+
+func NewStatWalScraper() Scraper {
+	return &statWalScraper{
+		records: prometheus.NewDesc(
+			"postgres_stat_wal_records_total",
+			"Total number of WAL records generated",
+			nil,
+			nil,
+		),
+		fpi: prometheus.NewDesc(
+			"postgres_stat_wal_fpi_total",
+			"Total number of WAL full page images generated",
+			nil,
+			nil,
+		),
+		bytes: prometheus.NewDesc(
+			"postgres_stat_wal_bytes_total",
+			"Total amount of WAL generated in bytes",
+			nil,
+			nil,
+		),
+		buffersFull: prometheus.NewDesc(
+			"postgres_stat_wal_buffers_full_total",
+			"Number of times WAL data was written to disk because WAL buffers became full",
+			nil,
+			nil,
+		),
+		write: prometheus.NewDesc(
+			"postgres_stat_wal_write_total",
+			"Number of times WAL buffers were written out to disk",
+			nil,
+			nil,
+		),
+		sync: prometheus.NewDesc(
+			"postgres_stat_wal_sync_total",
+			"Number of times WAL files were synced to disk",
+			nil,
+			nil,
+		),
+		writeTime: prometheus.NewDesc(
+			"postgres_stat_wal_write_time_seconds_total",
+			"Total amount of time spent writing WAL buffers to disk, requires track_wal_io_timing",
+			nil,
+			nil,
+		),
+		syncTime: prometheus.NewDesc(
+			"postgres_stat_wal_sync_time_seconds_total",
+			"Total amount of time spent syncing WAL files to disk, requires track_wal_io_timing",
+			nil,
+			nil,
+		),
+		statsReset: prometheus.NewDesc(
+			"postgres_stat_wal_stats_reset_timestamp",
+			"Time at which these statistics were last reset",
+			nil,
+			nil,
+		),
+	}
+}
+
+func (*statWalScraper) Name() string {
+	return "StatWalScraper"
+}
+
+func (c *statWalScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.records
+	ch <- c.fpi
+	ch <- c.bytes
+	ch <- c.buffersFull
+	ch <- c.write
+	ch <- c.sync
+	ch <- c.writeTime
+	ch <- c.syncTime
+	ch <- c.statsReset
+}
+
+func (c *statWalScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(statWalVersion) {
+		return nil
+	}
+
+	query := statWal14
+	if version.Gte(statWal18Version) {
+		query = statWal18
+	}
+
+	var records, fpi, buffersFull int64
+	var bytes float64
+	// NULL on PostgreSQL 18+
+	var write, sync *int64
+	var writeTime, syncTime *float64
+	var statsReset time.Time
+
+	if err := conn.QueryRow(ctx, query).
+		Scan(&records,
+			&fpi,
+			&bytes,
+			&buffersFull,
+			&write,
+			&sync,
+			&writeTime,
+			&syncTime,
+			&statsReset,
+		); err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.records, prometheus.CounterValue, float64(records))
+	ch <- prometheus.MustNewConstMetric(c.fpi, prometheus.CounterValue, float64(fpi))
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, bytes)
+	ch <- prometheus.MustNewConstMetric(c.buffersFull, prometheus.CounterValue, float64(buffersFull))
+	emitCount(ch, c.write, write, nil)
+	emitCount(ch, c.sync, sync, nil)
+	emitFloat(ch, c.writeTime, writeTime, 1000, nil)
+	emitFloat(ch, c.syncTime, syncTime, 1000, nil)
+	ch <- prometheus.MustNewConstMetric(c.statsReset, prometheus.GaugeValue, float64(statsReset.UTC().Unix()))
+	return nil
+}
@@ -0,0 +1,217 @@
+package collector
+
+import (
+	"context"
+	"strconv"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Scrape queries, pg_stat_wal_receiver has no row when no WAL receiver
+	// is running, e.g. while restoring from the archive. Without the
+	// privileges of pg_read_all_stats, every column but pid is null.
+	statWalReceiver11 = `
+SELECT status
+     , received_tli
+     , EXTRACT(EPOCH FROM now() - latest_end_time)::float8
+     , COALESCE(sender_host, '')
+     , COALESCE(sender_port, 0)
+  FROM pg_stat_wal_receiver /*postgres_exporter*/`
+
+	// Scrape query for PostgreSQL 10, which has no sender_host and sender_port
+	statWalReceiver10 = `
+SELECT status
+     , received_tli
+     , EXTRACT(EPOCH FROM now() - latest_end_time)::float8
+     , '' AS sender_host
+     , 0 AS sender_port
+  FROM pg_stat_wal_receiver /*postgres_exporter*/`
+
+	recoveryReplayQuery = `
+SELECT EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8
+     , pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn())::float8
+     , pg_is_wal_replay_paused() /*postgres_exporter*/`
+
+	statWalReceiverVersion           = 100000
+	statWalReceiverSenderHostVersion = 110000
+)
+
+type statWalReceiverScraper struct {
+	info               *prometheus.Desc
+	streaming          *prometheus.Desc
+	receivedTli        *prometheus.Desc
+	latestEndAge       *prometheus.Desc
+	replayDelay        *prometheus.Desc
+	receiveReplayBytes *prometheus.Desc
+	replayPaused       *prometheus.Desc
+}
+
+// NewStatWalReceiverScraper returns a new Scraper exposing PostgreSQL
+// `pg_stat_wal_receiver` view and the replay progress of a standby. It only
+// exposes metrics while the server is in recovery
+func NewStatWalReceiverScraper() Scraper {
+	return &statWalReceiverScraper{
+		info: prometheus.NewDesc(
+			"postgres_stat_wal_receiver_info",
+			"Status of the WAL receiver and the server it is connected to",
+			[]string{"status", "sender_host", "sender_port"},
+			nil,
+		),
+		streaming: prometheus.NewDesc(
+			"postgres_stat_wal_receiver_streaming",
+			"Whether the WAL receiver is streaming from the primary",
+			nil,
+			nil,
+		),
+		receivedTli: prometheus.NewDesc(
+			"postgres_stat_wal_receiver_received_tli",
+			"Timeline number of the last write-ahead log location received and flushed to disk",
+			nil,
+			nil,
+		),
+		latestEndAge: prometheus.NewDesc(
+			"postgres_stat_wal_receiver_latest_end_age_seconds",
+			"Time elapsed since the last write-ahead log location was reported to the origin WAL sender",
+			nil,
+			nil,
+		),
+		replayDelay: prometheus.NewDesc(
+			"postgres_recovery_replay_delay_seconds",
+			"Time elapsed since the commit of the last transaction replayed, now() - pg_last_xact_replay_timestamp()",
+			nil,
+			nil,
+		),
+		receiveReplayBytes: prometheus.NewDesc(
+			"postgres_recovery_receive_replay_lag_bytes",
+			"WAL received but not replayed yet in bytes, pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn())",
+			nil,
+			nil,
+		),
+		replayPaused: prometheus.NewDesc(
+			"postgres_recovery_replay_paused",
+			"Whether recovery is paused, pg_is_wal_replay_paused()",
+			nil,
+			nil,
+		),
+	}
+}
+
+func (*statWalReceiverScraper) Name() string {
+	return "StatWalReceiverScraper"
+}
+
+func (c *statWalReceiverScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	ch <- c.streaming
+	ch <- c.receivedTli
+	ch <- c.latestEndAge
+	ch <- c.replayDelay
+	ch <- c.receiveReplayBytes
+	ch <- c.replayPaused
+}
+
+func (c *statWalReceiverScraper) Scrape(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	if !version.Gte(statWalReceiverVersion) {
+		return nil
+	}
+
+	var recovery int64
+	if err := conn.QueryRow(ctx, isInRecoveryQuery).Scan(&recovery); err != nil {
+		return err
+	}
+	if recovery == 0 {
+		// primary, nothing to expose
+		return nil
+	}
+
+	if err := c.scrapeWalReceiver(ctx, conn, version, ch); err != nil {
+		return err
+	}
+
+	// NULL before the first transaction is replayed, and when not streaming
+	var replayDelay, receiveReplayBytes *float64
+	var replayPaused bool
+
+	if err := conn.QueryRow(ctx, recoveryReplayQuery).
+		Scan(&replayDelay,
+			&receiveReplayBytes,
+			&replayPaused,
+		); err != nil {
+		return err
+	}
+
+	emitGauge(ch, c.replayDelay, replayDelay, nil)
+	emitGauge(ch, c.receiveReplayBytes, receiveReplayBytes, nil)
+
+	paused := 0.0
+	if replayPaused {
+		paused = 1.0
+	}
+	ch <- prometheus.MustNewConstMetric(c.replayPaused, prometheus.GaugeValue, paused)
+
+	return nil
+}
+
+// scrapeWalReceiver scrapes pg_stat_wal_receiver.
+func (c *statWalReceiverScraper) scrapeWalReceiver(ctx context.Context, conn *pgx.Conn, version Version, ch chan<- prometheus.Metric) error {
+	query := statWalReceiver10
+	if version.Gte(statWalReceiverSenderHostVersion) {
+		query = statWalReceiver11
+	}
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	streaming := 0.0
+	statusKnown := true
+	var senderHost string
+	var senderPort int32
+	// NULL without the privileges of pg_read_all_stats
+	var status *string
+	// NULL until a location is reported to the WAL sender
+	var receivedTli *int32
+	var latestEndAge *float64
+
+	for rows.Next() {
+		if err := rows.Scan(&status,
+			&receivedTli,
+			&latestEndAge,
+			&senderHost,
+			&senderPort,
+		); err != nil {
+			return err
+		}
+
+		if status == nil {
+			// the WAL receiver runs, but its status cannot be read
+			statusKnown = false
+			continue
+		}
+		if *status == "streaming" {
+			streaming = 1.0
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, infoMetricValue,
+			*status, senderHost, strconv.Itoa(int(senderPort)))
+		if receivedTli != nil {
+			ch <- prometheus.MustNewConstMetric(c.receivedTli, prometheus.GaugeValue, float64(*receivedTli))
+		}
+		emitGauge(ch, c.latestEndAge, latestEndAge, nil)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// postgres_stat_wal_receiver_streaming is 0 when no WAL receiver runs
+	if statusKnown {
+		ch <- prometheus.MustNewConstMetric(c.streaming, prometheus.GaugeValue, streaming)
+	}
+
+	return nil
+}
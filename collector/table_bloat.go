@@ -0,0 +1,317 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// tableBloatQuery estimates the size of each table from its row count and
+	// the average width of its columns in pg_stats, the difference with the
+	// actual size being the bloat. Tables without statistics are skipped.
+	// Based on https://github.com/ioguix/pgsql-bloat-estimation
+	tableBloatQuery = `
+SELECT schemaname
+     , tblname
+     , bloat_bytes::float8
+     , CASE WHEN real_bytes > 0 THEN bloat_bytes / real_bytes ELSE 0 END::float8
+  FROM (
+    SELECT schemaname
+         , tblname
+         , bs * tblpages AS real_bytes
+         , CASE WHEN tblpages > est_tblpages_ff
+                THEN (tblpages - est_tblpages_ff) * bs
+                ELSE 0
+           END AS bloat_bytes
+         , is_na
+      FROM (
+        SELECT ceil(reltuples / ((bs - page_hdr) * fillfactor / (tpl_size * 100))) + ceil(toasttuples / 4) AS est_tblpages_ff
+             , tblpages, bs, schemaname, tblname, is_na
+          FROM (
+            SELECT (4 + tpl_hdr_size + tpl_data_size + (2 * ma)
+                    - CASE WHEN tpl_hdr_size % ma = 0 THEN ma ELSE tpl_hdr_size % ma END
+                    - CASE WHEN ceil(tpl_data_size)::int % ma = 0 THEN ma ELSE ceil(tpl_data_size)::int % ma END
+                   ) AS tpl_size
+                 , heappages + toastpages AS tblpages
+                 , reltuples, toasttuples, bs, page_hdr, schemaname, tblname, fillfactor, is_na
+              FROM (
+                SELECT ns.nspname AS schemaname
+                     , tbl.relname AS tblname
+                     , tbl.reltuples
+                     , tbl.relpages AS heappages
+                     , COALESCE(toast.relpages, 0) AS toastpages
+                     , COALESCE(toast.reltuples, 0) AS toasttuples
+                     , COALESCE(substring(array_to_string(tbl.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 100) AS fillfactor
+                     , current_setting('block_size')::numeric AS bs
+                     , 8 AS ma
+                     , 24 AS page_hdr
+                     , 23 + CASE WHEN max(COALESCE(s.null_frac, 0)) > 0 THEN (7 + count(s.attname)) / 8 ELSE 0::int END AS tpl_hdr_size
+                     , sum((1 - COALESCE(s.null_frac, 0)) * COALESCE(s.avg_width, 0)) AS tpl_data_size
+                     , bool_or(att.atttypid = 'pg_catalog.name'::regtype)
+                       OR sum(CASE WHEN att.attnum > 0 THEN 1 ELSE 0 END) <> count(s.attname) AS is_na
+                  FROM pg_attribute att
+                  JOIN pg_class tbl ON tbl.oid = att.attrelid
+                  JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
+                  LEFT JOIN pg_stats s ON s.schemaname = ns.nspname
+                                      AND s.tablename = tbl.relname
+                                      AND s.inherited = false
+                                      AND s.attname = att.attname
+                  LEFT JOIN pg_class toast ON toast.oid = tbl.reltoastrelid
+                 WHERE NOT att.attisdropped
+                   AND att.attnum > 0
+                   AND tbl.relkind IN ('r', 'm')
+                   AND ns.nspname NOT IN ('pg_catalog', 'information_schema')
+                 GROUP BY 1, 2, 3, 4, 5, 6, 7, 8
+              ) AS s
+          ) AS s2
+      ) AS s3
+  ) AS s4
+ WHERE NOT is_na /*postgres_exporter*/`
+
+	// tableBloatSchemaQuery returns the schema the pgstattuple extension is
+	// installed in
+	tableBloatSchemaQuery = `
+SELECT n.nspname
+  FROM pg_extension e
+  JOIN pg_namespace n ON n.oid = e.extnamespace
+ WHERE e.extname = 'pgstattuple' /*postgres_exporter*/`
+
+	// tableBloatApproxQuery measures the dead tuples and free space of the
+	// $1 largest tables with pgstattuple_approx(), which skips the pages
+	// marked all-visible in the visibility map, and subtracts the free space
+	// reserved by the fillfactor like the estimation. Only heap tables are
+	// supported, relam is 0 for tables before PostgreSQL 12, and temporary
+	// tables of other sessions cannot be read. The placeholder is filled with
+	// the schema of the extension.
+	tableBloatApproxQuery = `
+SELECT nspname
+     , relname
+     , bloat_bytes::float8
+     , CASE WHEN table_len > 0 THEN bloat_bytes::float8 / table_len ELSE 0 END
+  FROM (
+    SELECT c.nspname
+         , c.relname
+         , a.table_len
+         , GREATEST(a.dead_tuple_len + a.approx_free_space - a.table_len * (100 - c.fillfactor) / 100, 0) AS bloat_bytes
+      FROM (
+        SELECT c.oid
+             , n.nspname
+             , c.relname
+             , COALESCE(substring(array_to_string(c.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 100) AS fillfactor
+          FROM pg_class c
+          JOIN pg_namespace n ON n.oid = c.relnamespace
+         WHERE c.relkind IN ('r', 'm')
+           AND c.relpersistence <> 't'
+           AND (c.relam = 0 OR c.relam = (SELECT oid FROM pg_am WHERE amname = 'heap'))
+           AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+         ORDER BY pg_relation_size(c.oid) DESC
+         LIMIT $1
+      ) c
+         , LATERAL %s.pgstattuple_approx(c.oid) a
+  ) s /*postgres_exporter*/`
+
+	// DefaultTableBloatPgstattupleLimit is the default number of tables
+	// measured with pgstattuple_approx() in each database
+	DefaultTableBloatPgstattupleLimit = 20
+	// DefaultTableBloatPgstattupleInterval is the default time the
+	// pgstattuple_approx() measures are reused before measuring again
+	DefaultTableBloatPgstattupleInterval = time.Hour
+)
+
+// tableBloatMeasures keeps the pgstattuple_approx() measures between scrapes,
+// the scrapers being built again for every scrape request.
+var tableBloatMeasures = &bloatCache{entries: make(map[bloatCacheKey]bloatCacheEntry)}
+
+// bloatKey identifies a table in a database.
+type bloatKey struct {
+	schemaname string
+	relname    string
+}
+
+// bloat is the space wasted in a table.
+type bloat struct {
+	bytes float64
+	ratio float64
+}
+
+// bloatCacheKey identifies a database of a server.
+type bloatCacheKey struct {
+	host    string
+	port    uint16
+	datname string
+}
+
+type bloatCacheEntry struct {
+	measured time.Time
+	bloats   map[bloatKey]bloat
+}
+
+// bloatCache keeps the bloat measured in each database.
+type bloatCache struct {
+	mu      sync.Mutex
+	entries map[bloatCacheKey]bloatCacheEntry
+}
+
+// get returns the bloat measured in the database less than maxAge ago.
+func (c *bloatCache) get(key bloatCacheKey, maxAge time.Duration) (map[bloatKey]bloat, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.measured) >= maxAge {
+		return nil, false
+	}
+	return entry.bloats, true
+}
+
+// set stores the bloat measured in the database, and forgets the measures
+// older than maxAge, e.g. of databases or probe targets gone since.
+func (c *bloatCache) set(key bloatCacheKey, bloats map[bloatKey]bloat, maxAge time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	maps.DeleteFunc(c.entries, func(_ bloatCacheKey, entry bloatCacheEntry) bool {
+		return time.Since(entry.measured) >= maxAge
+	})
+	if maxAge > 0 {
+		c.entries[key] = bloatCacheEntry{measured: time.Now(), bloats: bloats}
+	}
+}
+
+type tableBloatScraper struct {
+	logger              *slog.Logger
+	pgstattuple         bool
+	pgstattupleLimit    int
+	pgstattupleInterval time.Duration
+	bloatBytes          *prometheus.Desc
+	bloatRatio          *prometheus.Desc
+}
+
+// NewTableBloatScraper returns a new Scraper exposing an estimation of the
+// bloat of each table. When pgstattuple is set and the extension is
+// installed, the pgstattupleLimit largest tables are measured with
+// pgstattuple_approx() instead, at most once every pgstattupleInterval
+func NewTableBloatScraper(logger *slog.Logger, pgstattuple bool, pgstattupleLimit int, pgstattupleInterval time.Duration) Scraper {
+	return &tableBloatScraper{
+		logger:              logger,
+		pgstattuple:         pgstattuple,
+		pgstattupleLimit:    pgstattupleLimit,
+		pgstattupleInterval: pgstattupleInterval,
+		bloatBytes: prometheus.NewDesc(
+			"postgres_table_bloat_bytes",
+			"Estimated space used by dead tuples and free space in this table beyond its fillfactor",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
+		bloatRatio: prometheus.NewDesc(
+			"postgres_table_bloat_ratio",
+			"Estimated fraction of this table used by dead tuples and free space, between 0 and 1",
+			[]string{"datname", "schemaname", "relname"},
+			nil,
+		),
+	}
+}
+
+func (*tableBloatScraper) Name() string {
+	return "TableBloatScraper"
+}
+
+func (c *tableBloatScraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bloatBytes
+	ch <- c.bloatRatio
+}
+
+func (c *tableBloatScraper) Scrape(ctx context.Context, conn *pgx.Conn, _ Version, ch chan<- prometheus.Metric) error {
+	var datname string
+	if err := conn.QueryRow(ctx, "SELECT current_database() /*postgres_exporter*/").Scan(&datname); err != nil {
+		return err
+	}
+
+	bloats := make(map[bloatKey]bloat)
+	if err := queryBloat(ctx, conn, bloats, tableBloatQuery); err != nil {
+		return err
+	}
+
+	if c.pgstattuple {
+		measures, err := c.measure(ctx, conn, datname)
+		if err != nil {
+			// the measure needs privileges and time the estimation does
+			// not, keep the estimation
+			c.logger.Warn("failed to measure table bloat with pgstattuple_approx()",
+				slog.String("datname", datname),
+				slog.Any(errorKey, err))
+		}
+		// the measures replace the estimations of the largest tables
+		maps.Copy(bloats, measures)
+	}
+
+	for key, b := range bloats {
+		ch <- prometheus.MustNewConstMetric(c.bloatBytes, prometheus.GaugeValue, b.bytes, datname, key.schemaname, key.relname)
+		ch <- prometheus.MustNewConstMetric(c.bloatRatio, prometheus.GaugeValue, b.ratio, datname, key.schemaname, key.relname)
+	}
+
+	return nil
+}
+
+// measure returns the bloat of the largest tables measured with
+// pgstattuple_approx(), reusing the previous measures of the database while
+// they are recent enough. It returns nothing when the extension is not
+// installed.
+func (c *tableBloatScraper) measure(ctx context.Context, conn *pgx.Conn, datname string) (map[bloatKey]bloat, error) {
+	key := bloatCacheKey{host: conn.Config().Host, port: conn.Config().Port, datname: datname}
+	if measures, ok := tableBloatMeasures.get(key, c.pgstattupleInterval); ok {
+		return measures, nil
+	}
+
+	var schema string
+	if err := conn.QueryRow(ctx, tableBloatSchemaQuery).Scan(&schema); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// extension not installed in this database
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	measures := make(map[bloatKey]bloat)
+	query := fmt.Sprintf(tableBloatApproxQuery, pgx.Identifier{schema}.Sanitize())
+	if err := queryBloat(ctx, conn, measures, query, c.pgstattupleLimit); err != nil {
+		return nil, err
+	}
+	tableBloatMeasures.set(key, measures, c.pgstattupleInterval)
+
+	return measures, nil
+}
+
+// queryBloat runs a bloat query and stores the bloat of each table returned
+// into bloats.
+func queryBloat(ctx context.Context, conn *pgx.Conn, bloats map[bloatKey]bloat, query string, args ...any) error {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var key bloatKey
+	var b bloat
+
+	for rows.Next() {
+		if err := rows.Scan(&key.schemaname,
+			&key.relname,
+			&b.bytes,
+			&b.ratio,
+		); err != nil {
+			return err
+		}
+		bloats[key] = b
+	}
+
+	return rows.Err()
+}
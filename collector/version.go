@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// flavorPostgres is the flavor of servers that are not a known managed
+	// service or fork
+	flavorPostgres = "postgres"
+
+	// versionQuery returns server_version_num, server_version and the flavor
+	// of the server, detected from the functions and roles managed services
+	// create
+	versionQuery = `
+SELECT current_setting('server_version_num')::int,
+       current_setting('server_version'),
+       CASE
+         WHEN EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'aurora_version') THEN 'aurora'
+         WHEN EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'rds_superuser') THEN 'rds'
+         WHEN EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'alloydbsuperuser') THEN 'alloydb'
+         WHEN EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'cloudsqlsuperuser') THEN 'cloudsql'
+         WHEN EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'azure_pg_admin') THEN 'azure'
+         ELSE 'postgres'
+       END /*postgres_exporter*/`
+)
+
+// Version is the version of a Postgres server. It is compared using the
+// server_version_num format, e.g. 160004 for 16.4 and 90624 for 9.6.24.
+type Version struct {
+	num    int
+	full   string
+	flavor string
+}
+
+// NewVersion returns the Version of a server from its server_version_num and
+// server_version settings and its flavor.
+func NewVersion(num int, full string, flavor string) Version {
+	return Version{
+		num:    num,
+		full:   full,
+		flavor: flavor,
+	}
+}
+
+// ParseVersion parses a server_version string, e.g. "16.4",
+// "16.4 (Debian 16.4-1.pgdg120+1)", "17beta1" or "9.6.24". Anything after
+// the leading numbers is ignored, and a missing minor version is 0.
+func ParseVersion(s string) (Version, error) {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	numbers := s
+	if end >= 0 {
+		numbers = s[:end]
+	}
+
+	parts := strings.Split(strings.TrimSuffix(numbers, "."), ".")
+	values := make([]int, 0, len(parts))
+	for _, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		values = append(values, value)
+	}
+	for len(values) < 3 {
+		values = append(values, 0)
+	}
+
+	major := values[0]
+	var num int
+	switch {
+	case major >= 10:
+		// 16.4 is 160004
+		num = major*10000 + values[1]
+	case major > 0:
+		// 9.6.24 is 90624
+		num = major*10000 + values[1]*100 + values[2]
+	default:
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	return NewVersion(num, s, flavorPostgres), nil
+}
+
+// Gte returns whether v is num or newer, num being in the
+// server_version_num format, e.g. 150000 for 15.0.
+func (v Version) Gte(num int) bool {
+	return v.num >= num
+}
+
+// Num returns the version in the server_version_num format.
+func (v Version) Num() int {
+	return v.num
+}
+
+// Major returns the major version, e.g. "16" for 16.4 and "9.6" for 9.6.24.
+func (v Version) Major() string {
+	if v.num >= 100000 {
+		return strconv.Itoa(v.num / 10000)
+	}
+	return fmt.Sprintf("%d.%d", v.num/10000, v.num/100%100)
+}
+
+// Minor returns the minor version, e.g. 4 for 16.4 and 24 for 9.6.24.
+func (v Version) Minor() int {
+	if v.num >= 100000 {
+		return v.num % 10000
+	}
+	return v.num % 100
+}
+
+// Full returns the server_version string, which may include the build and
+// distribution, e.g. "16.4 (Debian 16.4-1.pgdg120+1)".
+func (v Version) Full() string {
+	return v.full
+}
+
+// Flavor returns the kind of server: postgres, aurora, rds, alloydb, cloudsql
+// or azure.
+func (v Version) Flavor() string {
+	return v.flavor
+}
+
+// String returns the version in the major.minor form, e.g. "16.4" or "9.6.24".
+func (v Version) String() string {
+	return fmt.Sprintf("%s.%d", v.Major(), v.Minor())
+}
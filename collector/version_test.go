@@ -0,0 +1,116 @@
+package collector
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in     string
+		num    int
+		major  string
+		minor  int
+		string string
+	}{
+		{in: "16.4", num: 160004, major: "16", minor: 4, string: "16.4"},
+		{in: "16.4 (Debian 16.4-1.pgdg120+1)", num: 160004, major: "16", minor: 4, string: "16.4"},
+		{in: "13.16 (Ubuntu 13.16-1.pgdg22.04+1)", num: 130016, major: "13", minor: 16, string: "13.16"},
+		{in: "17beta1", num: 170000, major: "17", minor: 0, string: "17.0"},
+		{in: "18rc1", num: 180000, major: "18", minor: 0, string: "18.0"},
+		{in: "17devel", num: 170000, major: "17", minor: 0, string: "17.0"},
+		{in: "15.4-rds", num: 150004, major: "15", minor: 4, string: "15.4"},
+		{in: "14.10", num: 140010, major: "14", minor: 10, string: "14.10"},
+		{in: "10.23", num: 100023, major: "10", minor: 23, string: "10.23"},
+		{in: "16", num: 160000, major: "16", minor: 0, string: "16.0"},
+		{in: "9.6.24", num: 90624, major: "9.6", minor: 24, string: "9.6.24"},
+		{in: "9.6", num: 90600, major: "9.6", minor: 0, string: "9.6.0"},
+		{in: "9.4.26 on x86_64-pc-linux-gnu", num: 90426, major: "9.4", minor: 26, string: "9.4.26"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			v, err := ParseVersion(tt.in)
+			if err != nil {
+				t.Fatalf("ParseVersion(%q) returned error: %v", tt.in, err)
+			}
+			if v.Num() != tt.num {
+				t.Errorf("Num() = %d, want %d", v.Num(), tt.num)
+			}
+			if v.Major() != tt.major {
+				t.Errorf("Major() = %q, want %q", v.Major(), tt.major)
+			}
+			if v.Minor() != tt.minor {
+				t.Errorf("Minor() = %d, want %d", v.Minor(), tt.minor)
+			}
+			if v.String() != tt.string {
+				t.Errorf("String() = %q, want %q", v.String(), tt.string)
+			}
+			if v.Full() != tt.in {
+				t.Errorf("Full() = %q, want %q", v.Full(), tt.in)
+			}
+		})
+	}
+}
+
+func TestParseVersionInvalid(t *testing.T) {
+	for _, in := range []string{"", "beta", "PostgreSQL 16.4", ".5", "0.1"} {
+		if v, err := ParseVersion(in); err == nil {
+			t.Errorf("ParseVersion(%q) = %d, want error", in, v.Num())
+		}
+	}
+}
+
+func TestVersionFromServerVersionNum(t *testing.T) {
+	tests := []struct {
+		num    int
+		full   string
+		major  string
+		minor  int
+		string string
+	}{
+		{num: 180000, full: "18.0", major: "18", minor: 0, string: "18.0"},
+		{num: 170002, full: "17.2 (Homebrew)", major: "17", minor: 2, string: "17.2"},
+		{num: 160004, full: "16.4 (Debian 16.4-1.pgdg120+1)", major: "16", minor: 4, string: "16.4"},
+		// Aurora and Azure report the community version they are based on
+		{num: 150004, full: "15.4", major: "15", minor: 4, string: "15.4"},
+		{num: 100023, full: "10.23", major: "10", minor: 23, string: "10.23"},
+		{num: 90624, full: "9.6.24", major: "9.6", minor: 24, string: "9.6.24"},
+		{num: 90500, full: "9.5.0", major: "9.5", minor: 0, string: "9.5.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.full, func(t *testing.T) {
+			v := NewVersion(tt.num, tt.full, flavorPostgres)
+			if v.Major() != tt.major {
+				t.Errorf("Major() = %q, want %q", v.Major(), tt.major)
+			}
+			if v.Minor() != tt.minor {
+				t.Errorf("Minor() = %d, want %d", v.Minor(), tt.minor)
+			}
+			if v.String() != tt.string {
+				t.Errorf("String() = %q, want %q", v.String(), tt.string)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	v := NewVersion(90624, "9.6.24", flavorPostgres)
+
+	// 9.6 is older than 10 even though 9.6 > 9.10 as a float
+	if v.Gte(100000) {
+		t.Error("9.6.24 Gte(100000) = true, want false")
+	}
+	if !v.Gte(90600) || !v.Gte(90624) {
+		t.Error("9.6.24 Gte(9.6.x) = false, want true")
+	}
+	if v.Gte(90625) {
+		t.Error("9.6.24 Gte(90625) = true, want false")
+	}
+
+	v = NewVersion(150000, "15.0", "rds")
+	if !v.Gte(150000) || v.Gte(150001) {
+		t.Error("15.0 should be Gte 150000 and not Gte 150001")
+	}
+	if v.Flavor() != "rds" {
+		t.Errorf("Flavor() = %q, want %q", v.Flavor(), "rds")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/rnaveiras/postgres_exporter/collector"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	authModuleTypeUserPass = "userpass"
+	defaultPostgresPort    = "5432"
+)
+
+var (
+	validLogLevels  = []string{"debug", "info", "warn", "error"}
+	validLogFormats = []string{"logfmt", "json"}
+
+	// reservedAuthModuleOptions are the connection parameters set from the
+	// probed target and the userpass credentials, which the options of an
+	// auth module must not override
+	reservedAuthModuleOptions = []string{"host", "hostaddr", "port", "service", "user", "password"}
+)
+
+// fileConfig is the content of the --config.file YAML file. It mirrors
+// flagConfig: the settings present in the file override the command line
+// flags.
+type fileConfig struct {
+	flagConfig  `yaml:",inline"`
+	AuthModules map[string]authModule `yaml:"auth_modules"`
+}
+
+// authModule holds the credentials and connection options used by /probe to
+// connect to a target.
+type authModule struct {
+	Type     string            `yaml:"type"`
+	UserPass userPass          `yaml:"userpass"`
+	Options  map[string]string `yaml:"options"`
+}
+
+type userPass struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// runtimeConfig is the configuration in use. It is replaced as a whole when
+// the configuration is reloaded.
+type runtimeConfig struct {
+	flagConfig
+	connConfig  *pgx.ConnConfig
+	authModules map[string]authModule
+}
+
+// loadConfig merges the config file, if any, on top of the command line flags
+// and validates the result.
+func loadConfig(flags flagConfig) (*runtimeConfig, error) {
+	cfg := fileConfig{flagConfig: flags.clone()}
+
+	if flags.ConfigFile != "" {
+		content, err := os.ReadFile(flags.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error parsing config file %q: %w", flags.ConfigFile, err)
+		}
+	}
+
+	for name, module := range cfg.AuthModules {
+		if err := module.validate(); err != nil {
+			return nil, fmt.Errorf("auth module %q: %w", name, err)
+		}
+	}
+
+	if !slices.Contains(validLogLevels, cfg.LogLevel) {
+		return nil, fmt.Errorf("invalid log level %q", cfg.LogLevel)
+	}
+	if !slices.Contains(validLogFormats, cfg.LogFormat) {
+		return nil, fmt.Errorf("invalid log format %q", cfg.LogFormat)
+	}
+
+	if cfg.Sampler && cfg.SamplerInterval <= 0 {
+		return nil, errors.New("activity sampler interval must be positive")
+	}
+
+	if cfg.AdminWebConfig != "" && cfg.AdminListen == "" {
+		return nil, errors.New("admin web config file requires an admin listen address")
+	}
+	for _, path := range []string{cfg.WebConfigFile, cfg.AdminWebConfig} {
+		if err := web.Validate(path); err != nil {
+			return nil, fmt.Errorf("invalid web config file %q: %w", path, err)
+		}
+	}
+
+	var err error
+	cfg.customQueries, err = collector.LoadCustomQueries(cfg.CustomQueries)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.collectorConfig().Validate(); err != nil {
+		return nil, fmt.Errorf("invalid collector configuration: %w", err)
+	}
+
+	// ParseConfig creates a ConnConfig from a connection string.
+	connConfig, err := pgx.ParseConfig(cfg.DataSource)
+	if err != nil {
+		return nil, fmt.Errorf("error parse data source: %w", err)
+	}
+
+	return &runtimeConfig{
+		flagConfig:  cfg.flagConfig,
+		connConfig:  connConfig,
+		authModules: cfg.AuthModules,
+	}, nil
+}
+
+// clone returns a copy of f that does not share maps or slices with it, so
+// the config file can be decoded on top of it.
+func (f flagConfig) clone() flagConfig {
+	f.ExcludedDatabases = slices.Clone(f.ExcludedDatabases)
+	f.SettingsAllowlist = slices.Clone(f.SettingsAllowlist)
+	f.ActivityUsenames = slices.Clone(f.ActivityUsenames)
+	f.ActivityAppNames = slices.Clone(f.ActivityAppNames)
+	f.Collectors = maps.Clone(f.Collectors)
+	f.ScraperTimeouts = maps.Clone(f.ScraperTimeouts)
+	return f
+}
+
+// keepStatic copies from old the settings that cannot change without a
+// restart, and returns the names of the ones that were changed.
+func (f *flagConfig) keepStatic(old flagConfig) []string {
+	var changed []string
+
+	keep := func(name string, differs bool) {
+		if differs {
+			changed = append(changed, name)
+		}
+	}
+
+	keep("listen_address", f.ListenAddress != old.ListenAddress)
+	keep("metrics_path", f.MetricsPath != old.MetricsPath)
+	keep("web_config_file", f.WebConfigFile != old.WebConfigFile)
+	keep("admin_listen_address", f.AdminListen != old.AdminListen)
+	keep("admin_web_config_file", f.AdminWebConfig != old.AdminWebConfig)
+	keep("log_format", f.LogFormat != old.LogFormat)
+	keep("pprof", f.Pprof != old.Pprof)
+	keep("pool_max_conns", f.PoolMaxConns != old.PoolMaxConns)
+	keep("pool_idle_timeout", f.PoolIdleTimeout != old.PoolIdleTimeout)
+	keep("pool_health_check_period", f.PoolHealthCheck != old.PoolHealthCheck)
+	keep("max_connections", f.MaxConnections != old.MaxConnections)
+	keep("activity_sampler", f.Sampler != old.Sampler)
+	keep("activity_sampler_interval", f.SamplerInterval != old.SamplerInterval)
+
+	f.ListenAddress = old.ListenAddress
+	f.MetricsPath = old.MetricsPath
+	f.WebConfigFile = old.WebConfigFile
+	f.AdminListen = old.AdminListen
+	f.AdminWebConfig = old.AdminWebConfig
+	f.LogFormat = old.LogFormat
+	f.Pprof = old.Pprof
+	f.PoolMaxConns = old.PoolMaxConns
+	f.PoolIdleTimeout = old.PoolIdleTimeout
+	f.PoolHealthCheck = old.PoolHealthCheck
+	f.MaxConnections = old.MaxConnections
+	f.Sampler = old.Sampler
+	f.SamplerInterval = old.SamplerInterval
+
+	return changed
+}
+
+// validate checks the type and options of the auth module.
+func (m authModule) validate() error {
+	if m.Type != authModuleTypeUserPass {
+		return fmt.Errorf("unsupported type %q", m.Type)
+	}
+	for _, option := range reservedAuthModuleOptions {
+		if _, ok := m.Options[option]; ok {
+			return fmt.Errorf("option %q is set from the target or the credentials", option)
+		}
+	}
+	return nil
+}
+
+// connConfig builds the connection config used to reach the given target,
+// which is either host, host:port or the path to a unix socket directory.
+func (m authModule) connConfig(target string) (*pgx.ConnConfig, error) {
+	host, port, err := splitTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"host": host,
+		"port": port,
+	}
+	if m.UserPass.Username != "" {
+		params["user"] = m.UserPass.Username
+	}
+	if m.UserPass.Password != "" {
+		params["password"] = m.UserPass.Password
+	}
+	for k, v := range m.Options {
+		params[k] = v
+	}
+
+	return pgx.ParseConfig(connString(params))
+}
+
+// splitTarget splits a probe target into host and port.
+func splitTarget(target string) (string, string, error) {
+	if target == "" {
+		return "", "", errors.New("empty target")
+	}
+
+	// pgx reads a comma separated host or port as a list of servers to try
+	if strings.Contains(target, ",") {
+		return "", "", fmt.Errorf("invalid target %q: multiple hosts are not supported", target)
+	}
+
+	// unix socket directory
+	if strings.HasPrefix(target, "/") {
+		return target, defaultPostgresPort, nil
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		var addrErr *net.AddrError
+		if errors.As(err, &addrErr) && addrErr.Err == "missing port in address" {
+			return strings.Trim(target, "[]"), defaultPostgresPort, nil
+		}
+		return "", "", fmt.Errorf("invalid target %q: %w", target, err)
+	}
+
+	return host, port, nil
+}
+
+// connString renders params as a libpq keyword/value connection string.
+func connString(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	quoter := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"='"+quoter.Replace(params[k])+"'")
+	}
+
+	return strings.Join(pairs, " ")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -23,6 +25,7 @@ import (
 	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/version"
+	"github.com/prometheus/exporter-toolkit/web"
 	"github.com/rnaveiras/postgres_exporter/collector"
 )
 
@@ -31,8 +34,10 @@ const (
 	exitCodeError = 1
 
 	// Server timeouts
-	readTimeout       = 5 * time.Second
-	writeTimeout      = 10 * time.Second
+	readTimeout = 5 * time.Second
+	// writeTimeout bounds the scrape duration, keep it above the largest
+	// scrape_timeout configured in Prometheus
+	writeTimeout      = 60 * time.Second
 	idleTimeout       = 120 * time.Second
 	readHeaderTimeout = 5 * time.Second
 
@@ -46,13 +51,41 @@ const (
 var handlerLock sync.Mutex
 
 type flagConfig struct {
-	ListenAddress     string   `json:"listen_address"`
-	MetricsPath       string   `json:"metrics_path"`
-	DataSource        string   `json:"data_source"`
-	LogLevel          string   `json:"log_level"`
-	LogFormat         string   `json:"log_format"`
-	Pprof             bool     `json:"pprof"`
-	ExcludedDatabases []string `json:"excluded_databases"`
+	ListenAddress     string                   `json:"listen_address" yaml:"listen_address"`
+	MetricsPath       string                   `json:"metrics_path" yaml:"metrics_path"`
+	WebConfigFile     string                   `json:"web_config_file" yaml:"web_config_file"`
+	AdminListen       string                   `json:"admin_listen_address" yaml:"admin_listen_address"`
+	AdminWebConfig    string                   `json:"admin_web_config_file" yaml:"admin_web_config_file"`
+	DataSource        string                   `json:"data_source" yaml:"data_source"`
+	ConfigFile        string                   `json:"config_file" yaml:"-"`
+	LogLevel          string                   `json:"log_level" yaml:"log_level"`
+	LogFormat         string                   `json:"log_format" yaml:"log_format"`
+	Pprof             bool                     `json:"pprof" yaml:"pprof"`
+	ExcludedDatabases []string                 `json:"excluded_databases" yaml:"excluded_databases"`
+	PoolMaxConns      int32                    `json:"pool_max_conns" yaml:"pool_max_conns"`
+	PoolIdleTimeout   time.Duration            `json:"pool_idle_timeout" yaml:"pool_idle_timeout"`
+	PoolHealthCheck   time.Duration            `json:"pool_health_check_period" yaml:"pool_health_check_period"`
+	MaxConnections    int                      `json:"max_connections" yaml:"max_connections"`
+	ScrapeConcurrency int                      `json:"scrape_concurrency" yaml:"scrape_concurrency"`
+	TimeoutOffset     time.Duration            `json:"scrape_timeout_offset" yaml:"scrape_timeout_offset"`
+	ScraperTimeout    time.Duration            `json:"scraper_timeout" yaml:"scraper_timeout"`
+	ScraperTimeouts   map[string]time.Duration `json:"scraper_timeouts" yaml:"scraper_timeouts"`
+	CustomQueries     string                   `json:"custom_queries" yaml:"custom_queries"`
+	Collectors        map[string]bool          `json:"collectors" yaml:"collectors"`
+	StatementsLimit   int                      `json:"stat_statements_limit" yaml:"stat_statements_limit"`
+	BloatPgstattuple  bool                     `json:"table_bloat_pgstattuple" yaml:"table_bloat_pgstattuple"`
+	BloatLimit        int                      `json:"table_bloat_pgstattuple_limit" yaml:"table_bloat_pgstattuple_limit"`
+	BloatInterval     time.Duration            `json:"table_bloat_pgstattuple_interval" yaml:"table_bloat_pgstattuple_interval"`
+	SettingsAllowlist []string                 `json:"settings_allowlist" yaml:"settings_allowlist"`
+	LockWaitsLimit    int                      `json:"lock_waits_limit" yaml:"lock_waits_limit"`
+	ActivityUsenames  []string                 `json:"stat_activity_usenames" yaml:"stat_activity_usenames"`
+	ActivityAppNames  []string                 `json:"stat_activity_application_names" yaml:"stat_activity_application_names"`
+	ActivityLimit     int                      `json:"stat_activity_limit" yaml:"stat_activity_limit"`
+	Sampler           bool                     `json:"activity_sampler" yaml:"activity_sampler"`
+	SamplerInterval   time.Duration            `json:"activity_sampler_interval" yaml:"activity_sampler_interval"`
+
+	// customQueries holds the queries loaded from CustomQueries
+	customQueries []collector.CustomQuery
 }
 
 // LogValue implemnts LogValuer interface
@@ -60,14 +93,164 @@ func (f flagConfig) LogValue() slog.Value {
 	return slog.GroupValue(
 		slog.String("listen_address", f.ListenAddress),
 		slog.String("metrics_path", f.MetricsPath),
+		slog.String("web_config_file", f.WebConfigFile),
+		slog.String("admin_listen_address", f.AdminListen),
+		slog.String("admin_web_config_file", f.AdminWebConfig),
+		slog.String("config_file", f.ConfigFile),
 		slog.String("log_level", f.LogLevel),
 		slog.String("log_format", f.LogFormat),
 		slog.Bool("pprof", f.Pprof),
 		slog.Any("exclude_databases", f.ExcludedDatabases),
+		slog.Int("pool_max_conns", int(f.PoolMaxConns)),
+		slog.Duration("pool_idle_timeout", f.PoolIdleTimeout),
+		slog.Duration("pool_health_check_period", f.PoolHealthCheck),
+		slog.Int("max_connections", f.MaxConnections),
+		slog.Int("scrape_concurrency", f.ScrapeConcurrency),
+		slog.Duration("scrape_timeout_offset", f.TimeoutOffset),
+		slog.Duration("scraper_timeout", f.ScraperTimeout),
+		slog.Any("scraper_timeouts", f.ScraperTimeouts),
+		slog.String("custom_queries", f.CustomQueries),
+		slog.Any("collectors", f.Collectors),
+		slog.Int("stat_statements_limit", f.StatementsLimit),
+		slog.Bool("table_bloat_pgstattuple", f.BloatPgstattuple),
+		slog.Int("table_bloat_pgstattuple_limit", f.BloatLimit),
+		slog.Duration("table_bloat_pgstattuple_interval", f.BloatInterval),
+		slog.Any("settings_allowlist", f.SettingsAllowlist),
+		slog.Int("lock_waits_limit", f.LockWaitsLimit),
+		slog.Any("stat_activity_usenames", f.ActivityUsenames),
+		slog.Any("stat_activity_application_names", f.ActivityAppNames),
+		slog.Int("stat_activity_limit", f.ActivityLimit),
+		slog.Bool("activity_sampler", f.Sampler),
+		slog.Duration("activity_sampler_interval", f.SamplerInterval),
 	)
 }
 
+// collectorConfig returns the settings used to build a collector.Exporter.
+func (f flagConfig) collectorConfig() collector.Config {
+	return collector.Config{
+		ExcludedDatabases:             f.ExcludedDatabases,
+		Collectors:                    f.Collectors,
+		Concurrency:                   f.ScrapeConcurrency,
+		ScraperTimeout:                f.ScraperTimeout,
+		ScraperTimeouts:               f.ScraperTimeouts,
+		CustomQueries:                 f.customQueries,
+		StatStatementsLimit:           f.StatementsLimit,
+		TableBloatPgstattuple:         f.BloatPgstattuple,
+		TableBloatPgstattupleLimit:    f.BloatLimit,
+		TableBloatPgstattupleInterval: f.BloatInterval,
+		SettingsAllowlist:             f.SettingsAllowlist,
+		LockWaitsLimit:                f.LockWaitsLimit,
+		StatActivityUsenames:          f.ActivityUsenames,
+		StatActivityApplicationNames:  f.ActivityAppNames,
+		StatActivityLimit:             f.ActivityLimit,
+	}
+}
+
+// poolOptions returns the settings of the connection pools.
+func (f flagConfig) poolOptions() collector.PoolOptions {
+	return collector.PoolOptions{
+		MaxConns:          f.PoolMaxConns,
+		MaxConnIdleTime:   f.PoolIdleTimeout,
+		HealthCheckPeriod: f.PoolHealthCheck,
+		MaxConnections:    f.MaxConnections,
+	}
+}
+
 func main() {
+	cfg := parseFlags()
+
+	// The config file, if any, overrides the flags
+	rc, err := loadConfig(cfg)
+	if err != nil {
+		//nolint:revive // Exiting anyway, so we can ignore
+		fmt.Fprintln(os.Stderr, fmt.Errorf("error loading configuration: %w", err))
+		os.Exit(exitCodeError)
+	}
+
+	// Setup log level
+	logLevel := new(slog.LevelVar)
+	logger, err := setupLogger(logLevel, rc.LogFormat, rc.LogLevel)
+	if err != nil {
+		//nolint:revive // Exiting anyway, so we can ignore
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCodeError)
+	}
+
+	// Booting
+	logger.Info("starting postgres exporter", "version", version.Info())
+	logger.Info("", "build_context", version.BuildContext())
+
+	// Log cfg configuration
+	logger.Debug("cfg", "cfg", rc.flagConfig)
+
+	logger.Info("connection string",
+		"user", rc.connConfig.User,
+		"host", rc.connConfig.Host,
+		"dbname", rc.connConfig.Database,
+		"port", rc.connConfig.Port,
+	)
+
+	configureConnConfig(rc.connConfig, logger)
+
+	reloader := newConfigReloader(logger, logLevel, cfg, rc)
+	prometheus.MustRegister(reloader)
+
+	// Connection pools outlive scrape requests, so connections are reused
+	// between scrapes
+	pools := collector.NewPools(rc.poolOptions())
+	defer pools.Close()
+	prometheus.MustRegister(pools)
+
+	// The activity sampler polls the server between scrapes, on its own
+	// connection, until the exporter stops
+	samplerCtx, stopSampler := context.WithCancel(context.Background())
+	defer stopSampler()
+	startSampler(samplerCtx, logger, reloader)
+
+	mux, adminMux := newMuxes(logger, logLevel, rc, pools, reloader)
+
+	logger = logger.With("component", "web")
+	servers := startServers(logger, rc, mux, adminMux)
+
+	// Reload the configuration on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			// errors are logged and exposed by the reloader
+			_ = reloader.reload()
+		}
+	}()
+
+	logger.Info("ready")
+
+	// Create a context that will be canceled on receiving a shutdown signal
+	// signal.NotifyContext handles signal setup and cleanup automatically
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Wait for interrupt signal
+	<-ctx.Done()
+
+	logger.Info("shutting down server - received signal",
+		errorKey, ctx.Err())
+
+	// Create a deadline to wait for current operations to complete
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("server forced to shutdown",
+				slog.Any(errorKey, err))
+		}
+	}
+
+	logger.Info("server gracefully stopped")
+}
+
+// parseFlags parses the command line flags, exiting on error.
+func parseFlags() flagConfig {
 	cfg := flagConfig{}
 
 	a := kingpin.New(filepath.Base(os.Args[0]), "The Postgres Exporter").UsageWriter(os.Stdout)
@@ -80,21 +263,104 @@ func main() {
 	a.Flag("web.telemetry-path", "Path under which to expose metrics").
 		Default("/metrics").StringVar(&cfg.MetricsPath)
 
+	a.Flag("web.config.file", "Path to a configuration file that can enable TLS or basic authentication, in the Prometheus web-config.yml format.").
+		StringVar(&cfg.WebConfigFile)
+
+	a.Flag("web.admin-listen-address", "Address on which to expose the admin endpoints (/admin/loglevel, /-/reload and pprof). Leave blank to expose them on --web.listen-address.").
+		StringVar(&cfg.AdminListen)
+
+	a.Flag("web.admin-config.file", "Path to a configuration file that can enable TLS or basic authentication on --web.admin-listen-address, in the Prometheus web-config.yml format.").
+		StringVar(&cfg.AdminWebConfig)
+
 	a.Flag("db.data-source", "libpq compatible connection string, e.g `user=postgres host=/var/run/postgresql`. Leave blank for libqp envs").
 		StringVar(&cfg.DataSource)
 
+	a.Flag("config.file", "Path to a YAML configuration file overriding the flags and holding the auth modules used by /probe. Reloaded on SIGHUP or a POST to /-/reload.").
+		StringVar(&cfg.ConfigFile)
+
 	a.Flag("db.excluded-databases", "Repeat this flag for each database to exclude from monitoring").
 		Default("cloudsdqladmin", "rdsadmin").StringsVar(&cfg.ExcludedDatabases)
 
+	a.Flag("db.pool.max-conns", "Maximum number of connections kept open to each database.").
+		Default("2").Int32Var(&cfg.PoolMaxConns)
+
+	a.Flag("db.pool.idle-timeout", "Duration after which an idle connection is closed. Keep it above the scrape interval to reuse connections.").
+		Default("5m").DurationVar(&cfg.PoolIdleTimeout)
+
+	a.Flag("db.pool.health-check-period", "Duration between health checks of idle connections.").
+		Default("1m").DurationVar(&cfg.PoolHealthCheck)
+
+	a.Flag("db.max-connections", "Maximum number of connections open at the same time across every database and target, idle ones included. Idle connections are closed to open new ones when the limit is reached. 0 means no limit.").
+		Default("8").IntVar(&cfg.MaxConnections)
+
+	a.Flag("scrape.concurrency", "Maximum number of global scrapers and databases scraped concurrently by each scrape request.").
+		Default("4").IntVar(&cfg.ScrapeConcurrency)
+
+	a.Flag("scrape.timeout-offset", "Offset to subtract from the timeout sent by Prometheus in the X-Prometheus-Scrape-Timeout-Seconds header.").
+		Default("500ms").DurationVar(&cfg.TimeoutOffset)
+
+	a.Flag("scrape.scraper-timeout", "Time limit of each scraper run. 0 means scrapers are only bounded by the scrape timeout.").
+		Default("0s").DurationVar(&cfg.ScraperTimeout)
+
+	scraperTimeouts := a.Flag("scrape.scraper-timeout-for", "Time limit of a single collector, overriding --scrape.scraper-timeout, e.g. disk_usage=5s. Repeat for each collector.").
+		PlaceHolder("COLLECTOR=DURATION").StringMap()
+
+	a.Flag("collector.custom-queries", "Path to a YAML file of user-defined queries to expose as metrics.").
+		StringVar(&cfg.CustomQueries)
+
+	a.Flag("collector.stat_statements.limit", "Number of statements exposed by the stat_statements collector, by total execution time. The others are folded into a queryid=\"other\" series per database and user.").
+		Default(strconv.Itoa(collector.DefaultStatStatementsLimit)).IntVar(&cfg.StatementsLimit)
+
+	a.Flag("collector.table_bloat.pgstattuple", "Measure table bloat with pgstattuple_approx() in the databases where the pgstattuple extension is installed, instead of estimating it from statistics.").
+		BoolVar(&cfg.BloatPgstattuple)
+
+	a.Flag("collector.table_bloat.pgstattuple-limit", "Number of the largest tables of each database measured with pgstattuple_approx(), the bloat of the others is estimated from statistics.").
+		Default(strconv.Itoa(collector.DefaultTableBloatPgstattupleLimit)).IntVar(&cfg.BloatLimit)
+
+	a.Flag("collector.table_bloat.pgstattuple-interval", "Time the pgstattuple_approx() measures of a database are reused before measuring again, 0 measures on every scrape.").
+		Default(collector.DefaultTableBloatPgstattupleInterval.String()).DurationVar(&cfg.BloatInterval)
+
+	a.Flag("collector.settings.allowlist", "String setting to expose with postgres_settings_info. Repeat this flag for each setting.").
+		Default(collector.DefaultSettingsAllowlist...).StringsVar(&cfg.SettingsAllowlist)
+
+	a.Flag("collector.lock_waits.limit", "Number of backends at the root of blocking chains exposed by the lock_waits collector, by number of backends waiting on them.").
+		Default(strconv.Itoa(collector.DefaultLockWaitsLimit)).IntVar(&cfg.LockWaitsLimit)
+
+	a.Flag("collector.stat_activity.usename", "User to count client connections for, the others are summed into usename=\"other\". Repeat this flag for each user. When not set, the users with the most connections are counted.").
+		StringsVar(&cfg.ActivityUsenames)
+
+	a.Flag("collector.stat_activity.application-name", "Application to count client connections for, the others are summed into application_name=\"other\". Repeat this flag for each application. When not set, the applications with the most connections are counted.").
+		StringsVar(&cfg.ActivityAppNames)
+
+	a.Flag("collector.stat_activity.limit", "Number of users and of applications with the most client connections counted by the stat_activity collector, when no allowlist is set.").
+		Default(strconv.Itoa(collector.DefaultStatActivityLimit)).IntVar(&cfg.ActivityLimit)
+
+	a.Flag("sampler.activity", "Sample pg_stat_activity in the background on a dedicated connection, to expose the time spent by backends in each state and wait event.").
+		Default("false").BoolVar(&cfg.Sampler)
+
+	a.Flag("sampler.activity.interval", "Time between two samples of the activity sampler.").
+		Default(collector.DefaultActivitySamplerInterval.String()).DurationVar(&cfg.SamplerInterval)
+
 	a.Flag("log.level", "Only log messages with the given severity or above. One of: [debug, info, warn, error]").
-		Default("info").EnumVar(&cfg.LogLevel, "debug", "info", "warn", "error")
+		Default("info").EnumVar(&cfg.LogLevel, validLogLevels...)
 
 	a.Flag("log.format", "Output format of log messages. One of: [logfmt, json]").
-		Default("logfmt").EnumVar(&cfg.LogFormat, "logfmt", "json")
+		Default("logfmt").EnumVar(&cfg.LogFormat, validLogFormats...)
 
 	a.Flag("web.enabled-pprof", "").
 		Default("false").BoolVar(&cfg.Pprof)
 
+	// --collector.<name> / --no-collector.<name> for each registered scraper
+	collectorFlags := make(map[string]*bool)
+	for _, s := range collector.Scrapers() {
+		state := "disabled"
+		if s.DefaultEnabled {
+			state = "enabled"
+		}
+		collectorFlags[s.Name] = a.Flag("collector."+s.Name, fmt.Sprintf("Enable the %s collector (default: %s).", s.Name, state)).
+			Default(strconv.FormatBool(s.DefaultEnabled)).Bool()
+	}
+
 	_, err := a.Parse(os.Args[1:])
 	if err != nil {
 		//nolint:revive // Exiting anyway, so we can ignore
@@ -103,65 +369,59 @@ func main() {
 		os.Exit(exitCodeError)
 	}
 
-	// Setup log level
-	logLevel := new(slog.LevelVar)
-	logger, err := setupLogger(logLevel, cfg.LogFormat, cfg.LogLevel)
-	if err != nil {
-		//nolint:revive // Exiting anyway, so we can ignore
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(exitCodeError)
+	cfg.Collectors = make(map[string]bool, len(collectorFlags))
+	for name, enabled := range collectorFlags {
+		cfg.Collectors[name] = *enabled
 	}
 
-	// Booting
-	logger.Info("starting postgres exporter", "version", version.Info())
-	logger.Info("", "build_context", version.BuildContext())
-
-	// Log cfg configuration
-	logger.Debug("cfg", "cfg", cfg)
-
-	// ParseConfig creates a ConnConfig from a connection string.
-	connConfig, err := pgx.ParseConfig(cfg.DataSource)
+	cfg.ScraperTimeouts, err = parseScraperTimeouts(*scraperTimeouts)
 	if err != nil {
-		logger.Error("error parse config",
-			slog.Any(errorKey, err))
+		//nolint:revive // Exiting anyway, so we can ignore
+		fmt.Fprintln(os.Stderr, fmt.Errorf("error invalid collector configuration: %w", err))
 		os.Exit(exitCodeError)
 	}
 
-	logger.Info("connection string",
-		"user", connConfig.User,
-		"host", connConfig.Host,
-		"dbname", connConfig.Database,
-		"port", connConfig.Port,
-	)
+	return cfg
+}
 
-	// Configure the connection tracer for PostgreSQL query logging
-	// - Uses a custom SlogAdapter to integrate with our structured logging
-	// - LogLevel is set to None by default to avoid excessive logging
-	// This tracer can be used to debug database operations if needed
-	// by changing the LogLevel to tracelog.LogLevelDebug
-	connConfig.Tracer = &tracelog.TraceLog{
-		Logger:   &SlogAdapter{logger: logger},
-		LogLevel: tracelog.LogLevelNone,
+// startSampler starts the activity sampler in the background when it is
+// enabled, until ctx is canceled. The sampler settings are static, but it
+// follows the data source through reloads.
+func startSampler(ctx context.Context, logger *slog.Logger, reloader *configReloader) {
+	rc := reloader.config()
+	if !rc.Sampler {
+		return
 	}
 
-	// Set PostgreSQL session parameters for this connection:
-	// - client_encoding: ensures proper character encoding (UTF8)
-	// - application_name: identifies this connection in pg_stat_activity
-	//   making it easier to track exporter connections in the database
-	connConfig.RuntimeParams = map[string]string{
-		"client_encoding":  "UTF8",
-		"application_name": "postgres_exporter",
+	connConfig := func() *pgx.ConnConfig {
+		return reloader.config().connConfig
 	}
+	sampler := collector.NewActivitySampler(logger.With("component", "sampler"), connConfig, rc.SamplerInterval)
+	prometheus.MustRegister(sampler)
+	go sampler.Run(ctx)
+}
 
+// newMuxes returns the servemux of the metrics endpoints and the one of the
+// admin endpoints, which are the same unless an admin address is set.
+func newMuxes(logger *slog.Logger, logLevel *slog.LevelVar, rc *runtimeConfig, pools *collector.Pools, reloader *configReloader) (*http.ServeMux, *http.ServeMux) {
 	// create a new servemux
 	mux := http.NewServeMux()
 	// register http endpoints
-	mux.Handle(cfg.MetricsPath, metricsHandler(logger, connConfig, cfg))
-	mux.Handle("/admin/loglevel", logLevelHandler(logger, logLevel))
-	mux.Handle("/", catchHandler(logger, cfg.MetricsPath))
+	mux.Handle(rc.MetricsPath, metricsHandler(logger, pools, reloader))
+	mux.Handle("/probe", probeHandler(logger, pools, reloader))
+	mux.Handle("/", catchHandler(logger, rc.MetricsPath))
+
+	// the admin endpoints get their own listener when an admin address is
+	// set, so they can be protected separately from the metrics
+	adminMux := mux
+	if rc.AdminListen != "" {
+		adminMux = http.NewServeMux()
+	}
+	adminMux.Handle("/admin/loglevel", logLevelHandler(logger, logLevel))
+	adminMux.Handle("/-/reload", reloadHandler(reloader))
 
 	// enable runtime profiling endpoints when pprof flag is set
-	if cfg.Pprof {
+	if rc.Pprof {
 		// Create a dedicated mux for pprof endpoints
 		debugMux := http.NewServeMux()
 		debugMux.HandleFunc("/debug/pprof/", pprof.Index)
@@ -170,17 +430,31 @@ func main() {
 		debugMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 		debugMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
-		mux.Handle("/debug/pprof", debugMux)
+		adminMux.Handle("/debug/pprof/", debugMux)
 	}
 
-	logger = logger.With("component", "web")
-	logger.Info("start listening for connections",
-		"address", cfg.ListenAddress,
-	)
+	return mux, adminMux
+}
 
-	server := &http.Server{
-		Addr:              cfg.ListenAddress,
-		Handler:           mux,
+// startServers serves mux, and adminMux on its own listener when an admin
+// address is set, in the background. It returns the servers to shut down.
+func startServers(logger *slog.Logger, rc *runtimeConfig, mux *http.ServeMux, adminMux *http.ServeMux) []*http.Server {
+	servers := []*http.Server{newServer(mux)}
+	go serve(logger, servers[0], rc.ListenAddress, rc.WebConfigFile)
+
+	if rc.AdminListen != "" {
+		adminServer := newServer(adminMux)
+		servers = append(servers, adminServer)
+		go serve(logger.With("listener", "admin"), adminServer, rc.AdminListen, rc.AdminWebConfig)
+	}
+
+	return servers
+}
+
+// newServer returns an HTTP server serving handler.
+func newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
 		ReadTimeout:       readTimeout,
 		WriteTimeout:      writeTimeout,
 		IdleTimeout:       idleTimeout,
@@ -188,38 +462,85 @@ func main() {
 
 		BaseContext: func(_ net.Listener) context.Context { return context.Background() },
 	}
+}
 
-	go func() {
-		err = server.ListenAndServe()
+// serve listens on address and serves server until it is shut down. TLS and
+// basic authentication are enabled by webConfigFile, if set.
+func serve(logger *slog.Logger, server *http.Server, address string, webConfigFile string) {
+	logger.Info("start listening for connections",
+		"address", address,
+	)
+
+	systemdSocket := false
+	flags := &web.FlagConfig{
+		WebListenAddresses: &[]string{address},
+		WebSystemdSocket:   &systemdSocket,
+		WebConfigFile:      &webConfigFile,
+	}
+
+	err := web.ListenAndServe(server, flags, logger)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("failed listen and server",
+			slog.Any(errorKey, err))
+	}
+}
+
+// parseScraperTimeouts parses the values of --scrape.scraper-timeout-for.
+func parseScraperTimeouts(values map[string]string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(values))
+	for name, value := range values {
+		timeout, err := time.ParseDuration(value)
 		if err != nil {
-			logger.Error("failed listen and server",
-				slog.Any(errorKey, err))
+			return nil, fmt.Errorf("invalid timeout for collector %q: %w", name, err)
 		}
-	}()
-
-	logger.Info("ready")
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
 
-	// Create a context that will be canceled on receiving a shutdown signal
-	// signal.NotifyContext handles signal setup and cleanup automatically
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+// scrapeContext returns a context bounded by the scrape timeout Prometheus
+// sends in the X-Prometheus-Scrape-Timeout-Seconds header, minus offset, so
+// the exporter can answer with partial results before Prometheus gives up.
+func scrapeContext(r *http.Request, offset time.Duration) (context.Context, context.CancelFunc) {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return context.WithCancel(r.Context())
+	}
 
-	// Wait for interrupt signal
-	<-ctx.Done()
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
 
-	logger.Info("shutting down server - received signal",
-		errorKey, ctx.Err())
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > offset {
+		timeout -= offset
+	}
 
-	// Create a deadline to wait for current operations to complete
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
-	defer cancel()
+	return context.WithTimeout(r.Context(), timeout)
+}
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		logger.Error("server forced to shutdown",
-			slog.Any(errorKey, err))
+// configureConnConfig sets the tracer and session parameters shared by every
+// connection the exporter opens.
+func configureConnConfig(connConfig *pgx.ConnConfig, logger *slog.Logger) {
+	// Configure the connection tracer for PostgreSQL query logging
+	// - Uses a custom SlogAdapter to integrate with our structured logging
+	// - LogLevel is set to None by default to avoid excessive logging
+	// This tracer can be used to debug database operations if needed
+	// by changing the LogLevel to tracelog.LogLevelDebug
+	connConfig.Tracer = &tracelog.TraceLog{
+		Logger:   &SlogAdapter{logger: logger},
+		LogLevel: tracelog.LogLevelNone,
 	}
 
-	logger.Info("server gracefully stopped")
+	// Set PostgreSQL session parameters for this connection:
+	// - client_encoding: ensures proper character encoding (UTF8)
+	// - application_name: identifies this connection in pg_stat_activity
+	//   making it easier to track exporter connections in the database
+	connConfig.RuntimeParams = map[string]string{
+		"client_encoding":  "UTF8",
+		"application_name": "postgres_exporter",
+	}
 }
 
 // catchHandler creates an HTTP handler that serves the index page of the exporter.
@@ -242,14 +563,19 @@ func catchHandler(logger *slog.Logger, metricsPath string) http.Handler {
 }
 
 // metricsHandler creates an HTTP handler that serves Prometheus metrics for PostgreSQL.
-func metricsHandler(logger *slog.Logger, connConfig *pgx.ConnConfig, cfg flagConfig) http.Handler {
+func metricsHandler(logger *slog.Logger, pools *collector.Pools, reloader *configReloader) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handlerLock.Lock()
 		defer handlerLock.Unlock()
 
+		cfg := reloader.config()
+
+		ctx, cancel := scrapeContext(r, cfg.TimeoutOffset)
+		defer cancel()
+
 		registry := prometheus.NewRegistry()
 		registry.MustRegister(versioncollector.NewCollector("postgres_exporter"))
-		registry.MustRegister(collector.NewExporter(r.Context(), logger, connConfig, cfg.ExcludedDatabases))
+		registry.MustRegister(collector.NewExporter(ctx, logger, pools, cfg.connConfig, cfg.collectorConfig()))
 
 		gatherers := prometheus.Gatherers{
 			prometheus.DefaultGatherer,
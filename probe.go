@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rnaveiras/postgres_exporter/collector"
+)
+
+// probeHandler creates an HTTP handler that scrapes the server given by the
+// target query parameter, using the credentials of the auth_module query
+// parameter. It follows the blackbox exporter multi-target pattern.
+func probeHandler(logger *slog.Logger, pools *collector.Pools, reloader *configReloader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := reloader.config()
+
+		params := r.URL.Query()
+
+		target := params.Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		moduleName := params.Get("auth_module")
+		if moduleName == "" {
+			http.Error(w, "auth_module parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		module, ok := cfg.authModules[moduleName]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown auth_module %q", moduleName), http.StatusBadRequest)
+			return
+		}
+
+		logger := logger.With("target", target, "auth_module", moduleName)
+
+		connConfig, err := module.connConfig(target)
+		if err != nil {
+			logger.Error("error building probe connection config",
+				slog.Any(errorKey, err))
+			http.Error(w, "invalid target", http.StatusBadRequest)
+			return
+		}
+		configureConnConfig(connConfig, logger)
+
+		ctx, cancel := scrapeContext(r, cfg.TimeoutOffset)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collector.NewExporter(ctx, logger, pools, connConfig, cfg.collectorConfig()))
+
+		h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			ErrorHandling: promhttp.ContinueOnError,
+		})
+		h.ServeHTTP(w, r)
+	})
+}
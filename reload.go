@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lastReloadSuccessfulDesc = prometheus.NewDesc(
+		"postgres_exporter_config_last_reload_successful",
+		"Whether the last configuration reload attempt was successful.",
+		nil,
+		nil,
+	)
+	lastReloadSuccessTimestampDesc = prometheus.NewDesc(
+		"postgres_exporter_config_last_reload_success_timestamp_seconds",
+		"Timestamp of the last successful configuration reload.",
+		nil,
+		nil,
+	)
+)
+
+// configReloader holds the configuration in use and replaces it when the
+// config file is reloaded. An invalid config file keeps the previous
+// configuration.
+type configReloader struct {
+	// mu serializes reloads, readers use current
+	mu       sync.Mutex
+	flags    flagConfig
+	logger   *slog.Logger
+	logLevel *slog.LevelVar
+	current  atomic.Pointer[runtimeConfig]
+
+	lastReloadSuccessful atomic.Bool
+	lastReloadSuccess    atomic.Int64
+}
+
+// Verify our configReloader satisfies the prometheus.Collector interface
+var _ prometheus.Collector = (*configReloader)(nil)
+
+// newConfigReloader returns a configReloader serving cfg, which was loaded
+// from flags.
+func newConfigReloader(logger *slog.Logger, logLevel *slog.LevelVar, flags flagConfig, cfg *runtimeConfig) *configReloader {
+	c := &configReloader{
+		flags:    flags,
+		logger:   logger,
+		logLevel: logLevel,
+	}
+	c.current.Store(cfg)
+	c.lastReloadSuccessful.Store(true)
+	c.lastReloadSuccess.Store(time.Now().Unix())
+	return c
+}
+
+// config returns the configuration in use.
+func (c *configReloader) config() *runtimeConfig {
+	return c.current.Load()
+}
+
+// reload loads the config file again and replaces the configuration in use.
+func (c *configReloader) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cfg, err := loadConfig(c.flags)
+	if err != nil {
+		c.lastReloadSuccessful.Store(false)
+		c.logger.Error("error reloading config, keeping the previous one",
+			slog.Any(errorKey, err))
+		return err
+	}
+
+	old := c.current.Load()
+	if changed := cfg.keepStatic(old.flagConfig); len(changed) > 0 {
+		c.logger.Warn("settings changed that require a restart, keeping the previous values",
+			slog.String("settings", strings.Join(changed, ",")))
+	}
+
+	configureConnConfig(cfg.connConfig, c.logger)
+	if err := setLogLevel(c.logLevel, cfg.LogLevel); err != nil {
+		// already validated by loadConfig
+		c.logger.Error("error setting log level",
+			slog.Any(errorKey, err))
+	}
+
+	c.current.Store(cfg)
+	c.lastReloadSuccessful.Store(true)
+	c.lastReloadSuccess.Store(time.Now().Unix())
+
+	c.logger.Info("config reloaded")
+	c.logger.Debug("cfg", "cfg", cfg.flagConfig)
+
+	return nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (*configReloader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastReloadSuccessfulDesc
+	ch <- lastReloadSuccessTimestampDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *configReloader) Collect(ch chan<- prometheus.Metric) {
+	successful := 0.0
+	if c.lastReloadSuccessful.Load() {
+		successful = 1.0
+	}
+
+	ch <- prometheus.MustNewConstMetric(lastReloadSuccessfulDesc, prometheus.GaugeValue, successful)
+	ch <- prometheus.MustNewConstMetric(lastReloadSuccessTimestampDesc, prometheus.GaugeValue, float64(c.lastReloadSuccess.Load()))
+}
+
+// reloadHandler creates an HTTP handler that reloads the configuration on POST.
+func reloadHandler(reloader *configReloader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := reloader.reload(); err != nil {
+			http.Error(w, "error reloading config: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}